@@ -1,3 +1,60 @@
+/**
+ * Shade periods of the chart, such as recessions or yield curve inversions.
+ * Each band covers the labels from its start to its end date, and is hidden
+ * along with the legend entry that shares its label.
+ */
+function bandsPlugin(bands) {
+  return {
+    id: "bands",
+    beforeDatasetsDraw(chart) {
+      if (!bands || bands.length === 0) return;
+
+      const labels = chart.data.labels;
+      const { ctx, chartArea, scales } = chart;
+      const x = scales.x;
+
+      ctx.save();
+      bands.forEach(function (band) {
+        const legend = chart.data.datasets.findIndex(function (d) {
+          return d.label === band.label;
+        });
+        if (legend >= 0 && !chart.isDatasetVisible(legend)) return;
+
+        // Dates are ISO formatted, so they compare as strings
+        const first = labels.findIndex(function (l) {
+          return l >= band.start;
+        });
+        let last = -1;
+        for (let i = labels.length - 1; i >= 0; i--) {
+          if (labels[i] <= band.end) {
+            last = i;
+            break;
+          }
+        }
+        if (first < 0 || last < first) return;
+
+        // Extend half a step either side so single points are visible
+        const step =
+          labels.length > 1
+            ? Math.abs(x.getPixelForValue(1) - x.getPixelForValue(0)) / 2
+            : 0;
+        const left = Math.max(x.getPixelForValue(first) - step, chartArea.left);
+        const right = Math.min(x.getPixelForValue(last) + step, chartArea.right);
+        if (right <= left) return;
+
+        ctx.fillStyle = band.color;
+        ctx.fillRect(
+          left,
+          chartArea.top,
+          right - left,
+          chartArea.bottom - chartArea.top,
+        );
+      });
+      ctx.restore();
+    },
+  };
+}
+
 /**
  * Initialize a Chart.js line chart with zoom and pan support
  */
@@ -26,6 +83,7 @@ function initChartFromData() {
         labels: config.labels,
         datasets: config.datasets,
       },
+      plugins: [bandsPlugin(config.bands)],
       options: {
         responsive: true,
         maintainAspectRatio: true,
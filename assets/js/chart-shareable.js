@@ -21,6 +21,8 @@
         input.checked = params.get(input.name) === input.value;
       } else if (input.type === "checkbox") {
         input.checked = params.get(input.name) === "on";
+      } else if (params.has(input.name)) {
+        input.value = params.get(input.name);
       }
     });
 
@@ -39,6 +41,8 @@
         } else {
           url.searchParams.set(input.name, "off");
         }
+      } else {
+        url.searchParams.set(input.name, input.value);
       }
     });
 
@@ -47,6 +51,8 @@
 
   document.body.addEventListener("htmx:beforeRequest", function (evt) {
     if (!suppressLoad) return;
+    // Only the chart controls' load trigger is replaced by the reload
+    if (!evt.detail.elt.closest(".chart-controls")) return;
     if (
       evt.detail.requestConfig &&
       evt.detail.requestConfig.trigger &&
@@ -12,18 +12,28 @@ import (
 	"strings"
 	"syscall"
 	"time"
+	"unicode"
 
 	root "github.com/shanehull/shanehull.com"
 	"github.com/shanehull/shanehull.com/internal/buildinfo"
+	"github.com/shanehull/shanehull.com/internal/cache"
+	"github.com/shanehull/shanehull.com/internal/fred"
 	"github.com/shanehull/shanehull.com/internal/handlers"
 	"github.com/shanehull/shanehull.com/internal/middleware"
 )
 
 var (
-	allowedOrigin = "*"
-	serverHost    = "127.0.0.1"
-	serverPort    = "1314"
-	logger        *slog.Logger
+	allowedOrigin    = "*"
+	serverHost       = "127.0.0.1"
+	serverPort       = "1314"
+	fredMode         = "live"
+	fredSnapshotsDir = "testdata/fred"
+	cacheDir         = ""
+	warmInterval     = time.Hour
+	adminToken       = ""
+	indicatorsDir    = ""
+	customSeries     = ""
+	logger           *slog.Logger
 )
 
 var content = &root.Public
@@ -64,6 +74,52 @@ func main() {
 
 	logger.Info(fmt.Sprintf("API routes allowed origin: %s", allowedOrigin))
 
+	// Check if FRED_MODE env var is set and override
+	envFredMode, ok := os.LookupEnv("FRED_MODE")
+	if ok {
+		fredMode = envFredMode
+	}
+
+	// Check if FRED_SNAPSHOTS_DIR env var is set and override
+	envSnapshotsDir, ok := os.LookupEnv("FRED_SNAPSHOTS_DIR")
+	if ok {
+		fredSnapshotsDir = envSnapshotsDir
+	}
+
+	// Check if CACHE_DIR env var is set and override
+	envCacheDir, ok := os.LookupEnv("CACHE_DIR")
+	if ok {
+		cacheDir = envCacheDir
+	}
+
+	// Check if CACHE_WARM_INTERVAL env var is set and override
+	envWarmInterval, ok := os.LookupEnv("CACHE_WARM_INTERVAL")
+	if ok {
+		interval, err := time.ParseDuration(envWarmInterval)
+		if err != nil || interval <= 0 {
+			log.Fatalf("invalid CACHE_WARM_INTERVAL %q", envWarmInterval)
+		}
+		warmInterval = interval
+	}
+
+	// Check if ADMIN_TOKEN env var is set and override
+	envAdminToken, ok := os.LookupEnv("ADMIN_TOKEN")
+	if ok {
+		adminToken = envAdminToken
+	}
+
+	// Check if INDICATORS_DIR env var is set and override
+	envIndicatorsDir, ok := os.LookupEnv("INDICATORS_DIR")
+	if ok {
+		indicatorsDir = envIndicatorsDir
+	}
+
+	// Check if CUSTOM_SERIES env var is set and override
+	envCustomSeries, ok := os.LookupEnv("CUSTOM_SERIES")
+	if ok {
+		customSeries = envCustomSeries
+	}
+
 	mux := http.NewServeMux()
 
 	// Custom file server handler
@@ -73,8 +129,50 @@ func main() {
 	// Serve all hugo content (the 'public' directory) at the root url
 	mux.Handle("/", fileServerWith404(fileServer, serverRoot))
 
+	// FRED client shared by the chart tools
+	mode, err := fred.ParseMode(fredMode)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fredClient := fred.NewClient(
+		fred.WithSnapshots(fred.NewSnapshotStore(fredSnapshotsDir), mode),
+	)
+	if mode != fred.ModeLive {
+		logger.Info("FRED snapshots enabled", "mode", mode, "dir", fredSnapshotsDir)
+	}
+
+	// Chart handlers own the caches, which are closed on shutdown and
+	// optionally persisted to disk
+	var cacheOpts []cache.Option
+	if cacheDir != "" {
+		store := cache.NewDiskStore(cacheDir, handlers.CacheVersion)
+		cacheOpts = append(cacheOpts, cache.WithStore(store))
+		logger.Info("persistent cache enabled", "dir", cacheDir)
+	}
+	charts := handlers.NewChartHandlers(fredClient, cacheOpts...)
+
+	// Register indicators defined in YAML alongside the built-in ones
+	if indicatorsDir != "" {
+		if err := charts.LoadIndicators(indicatorsDir); err != nil {
+			log.Fatal(err)
+		}
+		logger.Info("indicators loaded", "dir", indicatorsDir)
+	}
+
+	// Optionally limit the series custom formulas can use
+	if customSeries != "" {
+		ids := strings.FieldsFunc(customSeries, func(r rune) bool {
+			return r == ',' || unicode.IsSpace(r)
+		})
+		charts.AllowCustomSeries(ids...)
+		logger.Info("custom formula series limited", "series", customSeries)
+	}
+
+	// Warm the chart caches at startup and on a schedule
+	warm := newWarmer(charts, warmInterval)
+
 	// Register API handlers
-	registerHandlers(mux)
+	registerHandlers(mux, charts, warm)
 
 	// Wrap mux with CSP middleware
 	handler := middleware.CSP(mux)
@@ -90,6 +188,12 @@ func main() {
 
 	logger.Info("API Server available", "addr", serveAt)
 
+	warmDone := make(chan struct{})
+	go func() {
+		defer close(warmDone)
+		warm.run(ctx)
+	}()
+
 	// Wait for interrupt signal, then gracefully shut down
 	<-ctx.Done()
 	logger.Info("shutting down")
@@ -98,10 +202,12 @@ func main() {
 	if err := srv.Shutdown(shutdownCtx); err != nil {
 		logger.Error("forced shutdown", "error", err)
 	}
+	<-warmDone
+	charts.Close()
 	logger.Info("server stopped")
 }
 
-func registerHandlers(mux *http.ServeMux) {
+func registerHandlers(mux *http.ServeMux, charts *handlers.ChartHandlers, warm *warmer) {
 	// Quote API
 	mux.HandleFunc(
 		"/quote",
@@ -111,101 +217,69 @@ func registerHandlers(mux *http.ServeMux) {
 		),
 	)
 
-	// MS Index tool
-	mux.HandleFunc(
-		"/msindex/chart",
-		middleware.CORS(
-			http.HandlerFunc(handlers.MSIndexHandler),
-			allowedOrigin,
-		),
-	)
-	mux.HandleFunc(
-		"/msindex/downloads",
-		middleware.CORS(
-			http.HandlerFunc(handlers.MSIndexDownloadsHandler),
-			allowedOrigin,
-		),
-	)
-	mux.HandleFunc(
-		"/msindex/data",
-		middleware.CORS(
-			http.HandlerFunc(handlers.MSIndexDataHandler),
-			allowedOrigin,
-		),
-	)
-	mux.HandleFunc(
-		"/msindex/data.csv",
-		middleware.CORS(
-			http.HandlerFunc(handlers.MSIndexCSVHandler),
-			allowedOrigin,
-		),
-	)
+	// Chart tools, one set of routes per registered indicator plus the
+	// custom formula tool
+	for _, route := range charts.Routes() {
+		mux.HandleFunc(
+			route.Pattern,
+			middleware.CORS(route.Handler, allowedOrigin),
+		)
+	}
 
-	// Buffett Indicator tool
+	// Chart tool data sources
 	mux.HandleFunc(
-		"/buffett-indicator/chart",
+		"/{tool}/sources",
 		middleware.CORS(
-			http.HandlerFunc(handlers.BuffettIndicatorHandler),
-			allowedOrigin,
-		),
-	)
-	mux.HandleFunc(
-		"/buffett-indicator/downloads",
-		middleware.CORS(
-			http.HandlerFunc(handlers.BuffettIndicatorDownloadsHandler),
+			http.HandlerFunc(charts.SourcesHandler),
 			allowedOrigin,
 		),
 	)
+
+	// Health check
 	mux.HandleFunc(
-		"/buffett-indicator/data",
-		middleware.CORS(
-			http.HandlerFunc(handlers.BuffettIndicatorDataHandler),
-			allowedOrigin,
-		),
+		"/healthz",
+		http.HandlerFunc(handlers.HealthzHandler),
 	)
+
+	// Readiness check, ready once the chart caches have been warmed
 	mux.HandleFunc(
-		"/buffett-indicator/data.csv",
-		middleware.CORS(
-			http.HandlerFunc(handlers.BuffettIndicatorCSVHandler),
-			allowedOrigin,
-		),
+		"/readyz",
+		http.HandlerFunc(warm.readyzHandler),
 	)
 
-	// Real Interest Rate tool
+	// Cache admin, only available with a token
+	if adminToken == "" {
+		logger.Info("admin endpoints disabled, ADMIN_TOKEN is not set")
+		return
+	}
 	mux.HandleFunc(
-		"/real-interest-rate/chart",
-		middleware.CORS(
-			http.HandlerFunc(handlers.RealInterestRateHandler),
-			allowedOrigin,
+		"/admin/cache",
+		middleware.BearerToken(
+			http.HandlerFunc(charts.AdminCacheHandler),
+			adminToken,
 		),
 	)
 	mux.HandleFunc(
-		"/real-interest-rate/downloads",
-		middleware.CORS(
-			http.HandlerFunc(handlers.RealInterestRateDownloadsHandler),
-			allowedOrigin,
+		"/admin/cache/purge",
+		middleware.BearerToken(
+			http.HandlerFunc(charts.AdminCachePurgeHandler),
+			adminToken,
 		),
 	)
 	mux.HandleFunc(
-		"/real-interest-rate/data",
-		middleware.CORS(
-			http.HandlerFunc(handlers.RealInterestRateDataHandler),
-			allowedOrigin,
+		"/admin/cache/refresh/{tool}",
+		middleware.BearerToken(
+			http.HandlerFunc(charts.AdminCacheRefreshHandler),
+			adminToken,
 		),
 	)
 	mux.HandleFunc(
-		"/real-interest-rate/data.csv",
-		middleware.CORS(
-			http.HandlerFunc(handlers.RealInterestRateCSVHandler),
-			allowedOrigin,
+		"/admin/cache/refresh",
+		middleware.BearerToken(
+			http.HandlerFunc(charts.AdminCacheRefreshHandler),
+			adminToken,
 		),
 	)
-
-	// Health check
-	mux.HandleFunc(
-		"/healthz",
-		http.HandlerFunc(handlers.HealthzHandler),
-	)
 }
 
 func fileServerWith404(handler http.Handler, fs fs.FS) http.HandlerFunc {
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/shanehull/shanehull.com/internal/handlers"
+)
+
+// warmer keeps the chart caches warm by computing every chart at startup
+// and then on a schedule
+type warmer struct {
+	charts   *handlers.ChartHandlers
+	interval time.Duration
+
+	mu      sync.RWMutex
+	runs    int
+	lastRun time.Time
+	lastErr error
+}
+
+// warmStatus is the body of the readiness endpoint
+type warmStatus struct {
+	Ready     bool      `json:"ready"`
+	Warm      bool      `json:"warm"`
+	Runs      int       `json:"runs"`
+	LastRun   time.Time `json:"last_run,omitzero"`
+	LastError string    `json:"last_error,omitempty"`
+}
+
+func newWarmer(charts *handlers.ChartHandlers, interval time.Duration) *warmer {
+	return &warmer{charts: charts, interval: interval}
+}
+
+// run warms the caches immediately and then every interval until ctx is
+// done
+func (w *warmer) run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		w.warm(ctx)
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (w *warmer) warm(ctx context.Context) {
+	start := time.Now()
+	err := w.charts.Warm(ctx)
+	if ctx.Err() != nil {
+		return
+	}
+
+	if err != nil {
+		log.Print("failed to warm chart caches:", err)
+	} else {
+		logger.Info("chart caches warmed", "duration", time.Since(start))
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.runs++
+	w.lastRun = start
+	w.lastErr = err
+}
+
+func (w *warmer) status() warmStatus {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	status := warmStatus{
+		Ready:   w.runs > 0,
+		Warm:    w.runs > 0 && w.lastErr == nil,
+		Runs:    w.runs,
+		LastRun: w.lastRun,
+	}
+	if w.lastErr != nil {
+		status.LastError = w.lastErr.Error()
+	}
+	return status
+}
+
+// readyzHandler reports ready once the first warm run has finished, even if
+// some charts failed to load, so that a FRED outage doesn't take the whole
+// site out of rotation. The body reports whether every chart was warmed.
+func (w *warmer) readyzHandler(rw http.ResponseWriter, _ *http.Request) {
+	status := w.status()
+
+	rw.Header().Set("Content-Type", "application/json")
+	if !status.Ready {
+		rw.WriteHeader(http.StatusServiceUnavailable)
+	}
+	if err := json.NewEncoder(rw).Encode(status); err != nil {
+		log.Print("failed to write response:", err)
+	}
+}
@@ -1,73 +1,420 @@
-// Package cache implements a simple in memory cache
+// Package cache implements an in-memory TTL cache with LRU size bounds,
+// coalesced loads that serve stale entries while refreshing them, and an
+// optional persistent Store behind it
 package cache
 
 import (
+	"container/list"
+	"context"
+	"slices"
 	"sync"
 	"time"
 )
 
-type item struct {
-	value      any
+const defaultCleanupInterval = time.Minute
+
+// Clock tells the cache the current time
+type Clock interface {
+	Now() time.Time
+}
+
+// ClockFunc adapts a function to a Clock
+type ClockFunc func() time.Time
+
+func (f ClockFunc) Now() time.Time {
+	return f()
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+type item[K comparable, V any] struct {
+	key        K
+	value      V
+	size       int64
+	updatedAt  time.Time
 	expireTime time.Time
+
+	// refreshErr is the error from the last failed background refresh
+	refreshErr error
+}
+
+// Stats reports cache usage since the cache was created
+type Stats struct {
+	Hits        uint64 `json:"hits"`
+	StaleHits   uint64 `json:"stale_hits"`
+	Misses      uint64 `json:"misses"`
+	Evictions   uint64 `json:"evictions"`
+	Expirations uint64 `json:"expirations"`
+	Loads       uint64 `json:"loads"`
+	Restores    uint64 `json:"restores"`
+	Entries     int    `json:"entries"`
+	Bytes       int64  `json:"bytes"`
+}
+
+// Cache is a TTL cache with optional size bounds. When a bound is exceeded
+// the least recently used entries are evicted.
+type Cache[K comparable, V any] struct {
+	mu       sync.Mutex
+	data     map[K]*list.Element
+	lru      *list.List
+	maxItems int
+	maxBytes int64
+	sizeOf   func(V) int64
+	bytes    int64
+	stats    Stats
+	clock    Clock
+	grace    time.Duration
+	store    Store
+	calls    map[K]*call[V]
+
+	// removed are the keys of entries removed from memory that are yet to
+	// be deleted from the store, which is done after mu is released so
+	// readers don't wait on it
+	removed []K
+
+	// storeMu is held from a change in memory until the store has caught up
+	// with it, so the store applies changes in the same order as memory and
+	// never keeps an older value or a deleted one. It is taken before mu.
+	storeMu sync.Mutex
+
+	stop      chan struct{}
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+type config struct {
+	maxEntries      int
+	cleanupInterval time.Duration
+	clock           Clock
+	staleGrace      time.Duration
+	store           Store
+	ctx             context.Context
+}
+
+// Option configures a Cache
+type Option func(*config)
+
+// WithMaxEntries bounds the number of entries held in the cache
+func WithMaxEntries(n int) Option {
+	return func(c *config) {
+		c.maxEntries = n
+	}
 }
 
-type Cache struct {
-	data map[string]item
-	mu   sync.RWMutex
+// WithCleanupInterval sets how often expired entries are removed in the
+// background. Defaults to one minute; zero or less disables the cleanup
+// goroutine, leaving expired entries to be dropped when they are read.
+func WithCleanupInterval(interval time.Duration) Option {
+	return func(c *config) {
+		c.cleanupInterval = interval
+	}
 }
 
-func New() *Cache {
-	c := &Cache{
-		data: make(map[string]item),
+// WithClock sets the clock used for TTLs. Defaults to the system clock.
+func WithClock(clock Clock) Option {
+	return func(c *config) {
+		c.clock = clock
+	}
+}
+
+// WithStaleGrace keeps entries for a grace period after they expire. Get
+// ignores them, but GetOrLoad and GetOrLoadEntry serve them while
+// refreshing in the background, and keep serving them if the refresh fails.
+func WithStaleGrace(grace time.Duration) Option {
+	return func(c *config) {
+		c.staleGrace = grace
+	}
+}
+
+// WithContext stops the cleanup goroutine when ctx is done, as if Close had
+// been called
+func WithContext(ctx context.Context) Option {
+	return func(c *config) {
+		c.ctx = ctx
+	}
+}
+
+// New creates a cache. Call Close when it is no longer needed to stop the
+// cleanup goroutine.
+func New[K comparable, V any](opts ...Option) *Cache[K, V] {
+	return NewSized[K, V](0, nil, opts...)
+}
+
+// NewSized creates a cache that measures its values with sizeOf and bounds
+// their total size to maxBytes. A maxBytes of zero or less only tracks the
+// size, which is reported in Stats and Entries.
+func NewSized[K comparable, V any](maxBytes int64, sizeOf func(V) int64, opts ...Option) *Cache[K, V] {
+	cfg := config{
+		cleanupInterval: defaultCleanupInterval,
+		clock:           systemClock{},
+		ctx:             context.Background(),
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	c := &Cache[K, V]{
+		data:     make(map[K]*list.Element),
+		lru:      list.New(),
+		maxItems: cfg.maxEntries,
+		maxBytes: maxBytes,
+		sizeOf:   sizeOf,
+		clock:    cfg.clock,
+		grace:    cfg.staleGrace,
+		store:    cfg.store,
+		calls:    make(map[K]*call[V]),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	if cfg.cleanupInterval > 0 {
+		go c.cleanupExpired(cfg.ctx, cfg.cleanupInterval)
+	} else {
+		close(c.done)
 	}
-	// Cleanup expired items every 1 minute
-	go c.cleanupExpired()
 	return c
 }
 
-func (c *Cache) Set(key string, value any, ttl time.Duration) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	c.data[key] = item{
+// Close stops the cleanup goroutine and waits for it to exit. The cache
+// remains usable. Close is safe to call more than once.
+func (c *Cache[K, V]) Close() {
+	c.closeOnce.Do(func() {
+		close(c.stop)
+	})
+	<-c.done
+}
+
+func (c *Cache[K, V]) Set(key K, value V, ttl time.Duration) {
+	var size int64
+	if c.sizeOf != nil {
+		size = c.sizeOf(value)
+	}
+
+	now := c.clock.Now()
+	itm := &item[K, V]{
+		key:        key,
 		value:      value,
-		expireTime: time.Now().Add(ttl),
+		size:       size,
+		updatedAt:  now,
+		expireTime: now.Add(ttl),
+	}
+
+	if c.store != nil {
+		c.storeMu.Lock()
+		defer c.storeMu.Unlock()
+	}
+
+	c.mu.Lock()
+	if el, found := c.data[key]; found {
+		c.unlink(el)
+	}
+	c.data[key] = c.lru.PushFront(itm)
+	c.bytes += size
+	// The new value replaces any removal of the key still to reach the store
+	c.removed = slices.DeleteFunc(c.removed, func(k K) bool { return k == key })
+	c.evict()
+	c.mu.Unlock()
+
+	if c.store != nil {
+		c.persist(key, itm)
 	}
 }
 
-func (c *Cache) Get(key string) (any, bool) {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
+func (c *Cache[K, V]) Get(key K) (V, bool) {
+	c.restore(key)
+	defer c.unstoreRemoved()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var zero V
 
-	itm, found := c.data[key]
+	el, found := c.data[key]
 	if !found {
-		return nil, false
+		c.stats.Misses++
+		return zero, false
 	}
 
-	if time.Now().After(itm.expireTime) {
-		return nil, false
+	itm := el.Value.(*item[K, V])
+	if fresh, _ := c.check(el); !fresh {
+		c.stats.Misses++
+		return zero, false
 	}
 
+	c.lru.MoveToFront(el)
+	c.stats.Hits++
 	return itm.value, true
 }
 
-func (c *Cache) Delete(key string) {
+func (c *Cache[K, V]) Delete(key K) {
+	defer c.unstoreRemoved()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, found := c.data[key]; found {
+		c.remove(el)
+	}
+}
+
+// DeleteFunc deletes every entry whose key matches and returns how many
+// were deleted
+func (c *Cache[K, V]) DeleteFunc(match func(K) bool) int {
+	defer c.unstoreRemoved()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	deleted := 0
+	for key, el := range c.data {
+		if match(key) {
+			c.remove(el)
+			deleted++
+		}
+	}
+	return deleted
+}
+
+// EntryInfo describes a cache entry without its value
+type EntryInfo[K comparable] struct {
+	Key       K
+	UpdatedAt time.Time
+	ExpiresAt time.Time
+	Size      int64
+	Stale     bool
+}
+
+// Entries describes the entries in the cache, most recently used first.
+// Expired entries still within their stale grace period are included.
+func (c *Cache[K, V]) Entries() []EntryInfo[K] {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.clock.Now()
+	entries := make([]EntryInfo[K], 0, c.lru.Len())
+	for el := c.lru.Front(); el != nil; el = el.Next() {
+		itm := el.Value.(*item[K, V])
+		if now.After(itm.expireTime.Add(c.grace)) {
+			continue
+		}
+		entries = append(entries, EntryInfo[K]{
+			Key:       itm.key,
+			UpdatedAt: itm.updatedAt,
+			ExpiresAt: itm.expireTime,
+			Size:      itm.size,
+			Stale:     now.After(itm.expireTime),
+		})
+	}
+	return entries
+}
+
+// Len returns the number of entries in the cache, including expired entries
+// that have not yet been cleaned up
+func (c *Cache[K, V]) Len() int {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	delete(c.data, key)
+
+	return c.lru.Len()
 }
 
-func (c *Cache) cleanupExpired() {
-	ticker := time.NewTicker(1 * time.Minute)
+// Stats returns a snapshot of the cache counters
+func (c *Cache[K, V]) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	stats.Entries = c.lru.Len()
+	stats.Bytes = c.bytes
+	return stats
+}
+
+// evict drops least recently used entries until the cache is within its
+// bounds. The most recently set entry is always kept. Evicted entries stay
+// in the store, if there is one, to be restored when next read. Callers must
+// hold mu.
+func (c *Cache[K, V]) evict() {
+	for c.lru.Len() > 1 {
+		overEntries := c.maxItems > 0 && c.lru.Len() > c.maxItems
+		overBytes := c.maxBytes > 0 && c.bytes > c.maxBytes
+		if !overEntries && !overBytes {
+			return
+		}
+
+		c.unlink(c.lru.Back())
+		c.stats.Evictions++
+	}
+}
+
+// check reports whether an entry is fresh or, failing that, still within
+// its stale grace period. Entries past both are removed. Callers must hold
+// mu.
+func (c *Cache[K, V]) check(el *list.Element) (fresh, stale bool) {
+	itm := el.Value.(*item[K, V])
+	now := c.clock.Now()
+
+	switch {
+	case !now.After(itm.expireTime):
+		return true, false
+	case !now.After(itm.expireTime.Add(c.grace)):
+		return false, true
+	default:
+		c.remove(el)
+		c.stats.Expirations++
+		return false, false
+	}
+}
+
+// remove deletes an entry from memory and queues it to be deleted from the
+// store by unstoreRemoved, for entries that have expired or been deleted.
+// Callers must hold mu.
+func (c *Cache[K, V]) remove(el *list.Element) {
+	itm := c.unlink(el)
+	if c.store != nil {
+		c.removed = append(c.removed, itm.key)
+	}
+}
+
+// unlink deletes an entry from memory only. Callers must hold mu.
+func (c *Cache[K, V]) unlink(el *list.Element) *item[K, V] {
+	itm := c.lru.Remove(el).(*item[K, V])
+	delete(c.data, itm.key)
+	c.bytes -= itm.size
+	return itm
+}
+
+func (c *Cache[K, V]) cleanupExpired(ctx context.Context, interval time.Duration) {
+	defer close(c.done)
+
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
-	for range ticker.C {
-		c.mu.Lock()
-		now := time.Now()
-		for key, itm := range c.data {
-			if now.After(itm.expireTime) {
-				delete(c.data, key)
-			}
+	for {
+		select {
+		case <-ticker.C:
+			c.removeExpired()
+		case <-c.stop:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (c *Cache[K, V]) removeExpired() {
+	defer c.unstoreRemoved()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.clock.Now()
+	for _, el := range c.data {
+		if now.After(el.Value.(*item[K, V]).expireTime.Add(c.grace)) {
+			c.remove(el)
+			c.stats.Expirations++
 		}
-		c.mu.Unlock()
 	}
 }
@@ -0,0 +1,219 @@
+package cache
+
+import (
+	"context"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a Clock that only moves when advanced
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+}
+
+func newTestCache(t *testing.T, clock Clock, opts ...Option) *Cache[string, int] {
+	t.Helper()
+
+	c := New[string, int](append([]Option{WithClock(clock), WithCleanupInterval(0)}, opts...)...)
+	t.Cleanup(c.Close)
+	return c
+}
+
+// keys returns the keys in the cache, most recently used first
+func keys[K comparable, V any](c *Cache[K, V]) []K {
+	var keys []K
+	for _, e := range c.Entries() {
+		keys = append(keys, e.Key)
+	}
+	return keys
+}
+
+func TestMaxEntriesEvictsLeastRecentlyUsed(t *testing.T) {
+	c := newTestCache(t, newFakeClock(), WithMaxEntries(3))
+
+	c.Set("a", 1, time.Hour)
+	c.Set("b", 2, time.Hour)
+	c.Set("c", 3, time.Hour)
+
+	// Reading a makes b the least recently used
+	if _, ok := c.Get("a"); !ok {
+		t.Fatal("Get(a) missed")
+	}
+	c.Set("d", 4, time.Hour)
+
+	if got, want := keys(c), []string{"d", "a", "c"}; !slices.Equal(got, want) {
+		t.Errorf("keys = %v, want %v", got, want)
+	}
+	if _, ok := c.Get("b"); ok {
+		t.Error("Get(b) hit an evicted entry")
+	}
+
+	stats := c.Stats()
+	if stats.Evictions != 1 || stats.Entries != 3 {
+		t.Errorf("Stats() = %+v, want 1 eviction and 3 entries", stats)
+	}
+}
+
+func TestMaxBytes(t *testing.T) {
+	c := NewSized[string](10, func(v []byte) int64 { return int64(len(v)) },
+		WithClock(newFakeClock()), WithCleanupInterval(0))
+	t.Cleanup(c.Close)
+
+	c.Set("a", make([]byte, 4), time.Hour)
+	c.Set("b", make([]byte, 4), time.Hour)
+	if got := c.Stats().Bytes; got != 8 {
+		t.Fatalf("Bytes = %d, want 8", got)
+	}
+
+	// Replacing an entry swaps its size rather than adding to it
+	c.Set("a", make([]byte, 2), time.Hour)
+	if got := c.Stats().Bytes; got != 6 {
+		t.Fatalf("Bytes after replacing a = %d, want 6", got)
+	}
+
+	// b is the least recently used, so it goes to make room
+	c.Set("c", make([]byte, 6), time.Hour)
+	if got, want := keys(c), []string{"c", "a"}; !slices.Equal(got, want) {
+		t.Errorf("keys = %v, want %v", got, want)
+	}
+	if got := c.Stats().Bytes; got != 8 {
+		t.Errorf("Bytes = %d, want 8", got)
+	}
+
+	// An entry over the budget on its own is still kept
+	c.Set("d", make([]byte, 20), time.Hour)
+	if got, want := keys(c), []string{"d"}; !slices.Equal(got, want) {
+		t.Errorf("keys = %v, want %v", got, want)
+	}
+
+	c.Delete("d")
+	if stats := c.Stats(); stats.Bytes != 0 || stats.Entries != 0 {
+		t.Errorf("Stats() after Delete = %+v, want no entries or bytes", stats)
+	}
+}
+
+func TestNewSizedTracksWithoutBound(t *testing.T) {
+	c := NewSized[string](0, func(v string) int64 { return int64(len(v)) },
+		WithClock(newFakeClock()), WithCleanupInterval(0))
+	t.Cleanup(c.Close)
+
+	for i := range 100 {
+		c.Set(strconv.Itoa(i), "value", time.Hour)
+	}
+	if stats := c.Stats(); stats.Entries != 100 || stats.Bytes != 500 || stats.Evictions != 0 {
+		t.Errorf("Stats() = %+v, want 100 entries of 500 bytes and no evictions", stats)
+	}
+}
+
+func TestTTLExpiry(t *testing.T) {
+	clock := newFakeClock()
+	c := newTestCache(t, clock)
+
+	c.Set("a", 1, time.Minute)
+
+	clock.Advance(time.Minute)
+	if _, ok := c.Get("a"); !ok {
+		t.Fatal("Get() missed an entry at its expiry time")
+	}
+
+	clock.Advance(time.Second)
+	if _, ok := c.Get("a"); ok {
+		t.Fatal("Get() hit an expired entry")
+	}
+
+	stats := c.Stats()
+	if stats.Expirations != 1 || stats.Entries != 0 {
+		t.Errorf("Stats() = %+v, want 1 expiration and no entries", stats)
+	}
+}
+
+func TestClockFunc(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := newTestCache(t, ClockFunc(func() time.Time { return now }))
+
+	c.Set("a", 1, time.Minute)
+	entries := c.Entries()
+	if len(entries) != 1 || !entries[0].UpdatedAt.Equal(now) {
+		t.Errorf("Entries() = %+v, want an entry updated at %v", entries, now)
+	}
+}
+
+func TestCleanupRemovesExpired(t *testing.T) {
+	clock := newFakeClock()
+	c := newTestCache(t, clock, WithCleanupInterval(time.Millisecond))
+
+	c.Set("a", 1, time.Minute)
+	c.Set("b", 2, time.Hour)
+	clock.Advance(2 * time.Minute)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for c.Len() != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("cleanup did not remove the expired entry")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if got, want := keys(c), []string{"b"}; !slices.Equal(got, want) {
+		t.Errorf("keys = %v, want %v", got, want)
+	}
+}
+
+func TestCleanupDisabled(t *testing.T) {
+	clock := newFakeClock()
+	c := newTestCache(t, clock)
+
+	c.Set("a", 1, time.Minute)
+	clock.Advance(2 * time.Minute)
+
+	// Expired entries stay until they are read
+	if c.Len() != 1 {
+		t.Errorf("Len() = %d, want 1", c.Len())
+	}
+}
+
+func TestWithContextStopsCleanup(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	c := New[string, int](WithContext(ctx), WithCleanupInterval(time.Millisecond))
+	cancel()
+
+	closed := make(chan struct{})
+	go func() {
+		c.Close()
+		close(closed)
+	}()
+
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close() did not return after the context was cancelled")
+	}
+
+	// The cache is still usable
+	c.Set("a", 1, time.Minute)
+	if _, ok := c.Get("a"); !ok {
+		t.Error("Get() missed after Close()")
+	}
+	c.Close()
+}
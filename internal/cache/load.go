@@ -0,0 +1,158 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Loader loads the value for a cache miss and says how long to keep it
+type Loader[V any] func(ctx context.Context) (V, time.Duration, error)
+
+// Entry is a cached value along with how fresh it is
+type Entry[V any] struct {
+	Value V
+
+	// UpdatedAt is when the value was stored
+	UpdatedAt time.Time
+
+	// Stale reports whether the value has expired and is being served
+	// within the stale grace period
+	Stale bool
+
+	// RefreshErr is the error from the last failed background refresh of a
+	// stale value, if any
+	RefreshErr error
+}
+
+// call is an in-flight load shared by concurrent GetOrLoad callers
+type call[V any] struct {
+	done  chan struct{}
+	value V
+	err   error
+}
+
+// GetOrLoad returns the cached value for key, calling load on a miss and
+// caching its result. See GetOrLoadEntry.
+func (c *Cache[K, V]) GetOrLoad(ctx context.Context, key K, load Loader[V]) (V, error) {
+	entry, err := c.GetOrLoadEntry(ctx, key, load)
+	return entry.Value, err
+}
+
+// GetOrLoadEntry returns the cached entry for key, calling load on a miss
+// and caching its result. Concurrent misses for the same key share a single
+// call to load. Errors are returned to every waiting caller but not cached.
+//
+// An expired entry within the stale grace period is returned immediately
+// while load refreshes it in the background. If the refresh fails the
+// stale entry is kept, with the error recorded in RefreshErr.
+//
+// The load is not cancelled when ctx is, since other callers may be waiting
+// on it; a caller whose ctx ends stops waiting and returns ctx.Err().
+func (c *Cache[K, V]) GetOrLoadEntry(ctx context.Context, key K, load Loader[V]) (Entry[V], error) {
+	c.restore(key)
+
+	c.mu.Lock()
+
+	if el, found := c.data[key]; found {
+		fresh, stale := c.check(el)
+		if fresh || stale {
+			itm := el.Value.(*item[K, V])
+			c.lru.MoveToFront(el)
+			if fresh {
+				c.stats.Hits++
+			} else {
+				c.stats.StaleHits++
+				c.startLoad(ctx, key, load)
+			}
+
+			// Read the entry before unlocking, since a refresh may update it
+			entry := Entry[V]{
+				Value:      itm.value,
+				UpdatedAt:  itm.updatedAt,
+				Stale:      stale,
+				RefreshErr: itm.refreshErr,
+			}
+			c.mu.Unlock()
+			c.unstoreRemoved()
+
+			return entry, nil
+		}
+	}
+
+	c.stats.Misses++
+	cl := c.startLoad(ctx, key, load)
+	c.mu.Unlock()
+	c.unstoreRemoved()
+
+	select {
+	case <-cl.done:
+		if cl.err != nil {
+			return Entry[V]{}, cl.err
+		}
+		return Entry[V]{Value: cl.value, UpdatedAt: c.clock.Now()}, nil
+	case <-ctx.Done():
+		return Entry[V]{}, ctx.Err()
+	}
+}
+
+// Reload calls load for key even if it is cached, waiting for the result.
+// The cached value is replaced on success and kept on failure. A load
+// already in flight for key is shared rather than started again.
+func (c *Cache[K, V]) Reload(ctx context.Context, key K, load Loader[V]) (V, error) {
+	c.mu.Lock()
+	cl := c.startLoad(ctx, key, load)
+	c.mu.Unlock()
+
+	select {
+	case <-cl.done:
+		return cl.value, cl.err
+	case <-ctx.Done():
+		var zero V
+		return zero, ctx.Err()
+	}
+}
+
+// startLoad returns the in-flight load for key, starting one if there is
+// none. Callers must hold mu.
+func (c *Cache[K, V]) startLoad(ctx context.Context, key K, load Loader[V]) *call[V] {
+	if cl, inFlight := c.calls[key]; inFlight {
+		return cl
+	}
+
+	cl := &call[V]{done: make(chan struct{})}
+	c.calls[key] = cl
+	c.stats.Loads++
+	go c.load(context.WithoutCancel(ctx), key, cl, load)
+
+	return cl
+}
+
+func (c *Cache[K, V]) load(ctx context.Context, key K, cl *call[V], load Loader[V]) {
+	var ttl time.Duration
+
+	defer func() {
+		if r := recover(); r != nil {
+			cl.err = fmt.Errorf("cache: loader panicked: %v", r)
+		}
+
+		// Store the value before retiring the call so that a caller arriving
+		// in between finds it rather than starting another load
+		if cl.err == nil {
+			c.Set(key, cl.value, ttl)
+		}
+
+		c.mu.Lock()
+		if cl.err != nil {
+			if el, found := c.data[key]; found {
+				el.Value.(*item[K, V]).refreshErr = cl.err
+			}
+		}
+		delete(c.calls, key)
+		c.mu.Unlock()
+
+		close(cl.done)
+	}()
+
+	cl.value, ttl, cl.err = load(ctx)
+}
@@ -0,0 +1,215 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGetOrLoadCoalescesMisses(t *testing.T) {
+	c := newTestCache(t, newFakeClock())
+
+	var loads atomic.Int32
+	release := make(chan struct{})
+	load := func(context.Context) (int, time.Duration, error) {
+		loads.Add(1)
+		<-release
+		return 42, time.Minute, nil
+	}
+
+	const callers = 10
+	var wg sync.WaitGroup
+	values := make([]int, callers)
+	errs := make([]error, callers)
+	for i := range callers {
+		wg.Go(func() {
+			values[i], errs[i] = c.GetOrLoad(context.Background(), "a", load)
+		})
+	}
+
+	// Wait for every caller to be waiting on the load before releasing it
+	deadline := time.Now().Add(5 * time.Second)
+	for c.Stats().Misses < callers {
+		if time.Now().After(deadline) {
+			t.Fatal("callers did not all miss")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+
+	if n := loads.Load(); n != 1 {
+		t.Errorf("load called %d times, want 1", n)
+	}
+	for i := range callers {
+		if errs[i] != nil || values[i] != 42 {
+			t.Errorf("caller %d got %d, %v, want 42", i, values[i], errs[i])
+		}
+	}
+
+	// The loaded value is cached
+	if v, ok := c.Get("a"); !ok || v != 42 {
+		t.Errorf("Get() = %d, %t, want 42", v, ok)
+	}
+}
+
+func TestGetOrLoadErrorsNotCached(t *testing.T) {
+	c := newTestCache(t, newFakeClock())
+	errLoad := errors.New("load failed")
+
+	fail := func(context.Context) (int, time.Duration, error) {
+		return 0, 0, errLoad
+	}
+	if _, err := c.GetOrLoad(context.Background(), "a", fail); !errors.Is(err, errLoad) {
+		t.Fatalf("GetOrLoad() error = %v, want %v", err, errLoad)
+	}
+
+	succeed := func(context.Context) (int, time.Duration, error) {
+		return 1, time.Minute, nil
+	}
+	if v, err := c.GetOrLoad(context.Background(), "a", succeed); err != nil || v != 1 {
+		t.Errorf("GetOrLoad() after an error = %d, %v, want 1", v, err)
+	}
+	if n := c.Stats().Loads; n != 2 {
+		t.Errorf("Loads = %d, want 2", n)
+	}
+}
+
+func TestGetOrLoadPanic(t *testing.T) {
+	c := newTestCache(t, newFakeClock())
+
+	_, err := c.GetOrLoad(context.Background(), "a", func(context.Context) (int, time.Duration, error) {
+		panic("boom")
+	})
+	if err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Errorf("GetOrLoad() error = %v, want the panic", err)
+	}
+}
+
+func TestGetOrLoadContextCancelled(t *testing.T) {
+	c := newTestCache(t, newFakeClock())
+
+	release := make(chan struct{})
+	load := func(ctx context.Context) (int, time.Duration, error) {
+		<-release
+		// The load outlives the caller that started it
+		return 1, time.Minute, ctx.Err()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := c.GetOrLoad(ctx, "a", load); !errors.Is(err, context.Canceled) {
+		t.Fatalf("GetOrLoad() error = %v, want context.Canceled", err)
+	}
+
+	close(release)
+	v, err := c.GetOrLoad(context.Background(), "a", load)
+	if err != nil || v != 1 {
+		t.Errorf("GetOrLoad() = %d, %v, want 1", v, err)
+	}
+}
+
+func TestReload(t *testing.T) {
+	c := newTestCache(t, newFakeClock())
+	c.Set("a", 1, time.Hour)
+
+	v, err := c.Reload(context.Background(), "a", func(context.Context) (int, time.Duration, error) {
+		return 2, time.Hour, nil
+	})
+	if err != nil || v != 2 {
+		t.Fatalf("Reload() = %d, %v, want 2", v, err)
+	}
+
+	// A failed reload keeps the cached value
+	_, err = c.Reload(context.Background(), "a", func(context.Context) (int, time.Duration, error) {
+		return 0, 0, errors.New("load failed")
+	})
+	if err == nil {
+		t.Fatal("Reload() error = nil, want the load error")
+	}
+	if v, ok := c.Get("a"); !ok || v != 2 {
+		t.Errorf("Get() after a failed reload = %d, %t, want 2", v, ok)
+	}
+}
+
+func TestStaleGrace(t *testing.T) {
+	clock := newFakeClock()
+	c := newTestCache(t, clock, WithStaleGrace(time.Hour))
+	c.Set("a", 1, time.Minute)
+	clock.Advance(2 * time.Minute)
+
+	// Get ignores stale entries
+	if _, ok := c.Get("a"); ok {
+		t.Error("Get() hit a stale entry")
+	}
+
+	refreshed := make(chan struct{})
+	release := make(chan struct{})
+	refresh := func(context.Context) (int, time.Duration, error) {
+		close(refreshed)
+		<-release
+		return 2, time.Minute, nil
+	}
+
+	// The stale value is served while it is refreshed in the background
+	entry, err := c.GetOrLoadEntry(context.Background(), "a", refresh)
+	if err != nil || entry.Value != 1 || !entry.Stale {
+		t.Fatalf("GetOrLoadEntry() = %+v, %v, want stale 1", entry, err)
+	}
+	<-refreshed
+	close(release)
+
+	v, err := c.Reload(context.Background(), "a", refresh)
+	if err != nil || v != 2 {
+		t.Fatalf("waiting for the refresh = %d, %v, want 2", v, err)
+	}
+	entry, err = c.GetOrLoadEntry(context.Background(), "a", refresh)
+	if err != nil || entry.Value != 2 || entry.Stale {
+		t.Errorf("GetOrLoadEntry() after the refresh = %+v, %v, want fresh 2", entry, err)
+	}
+	if stats := c.Stats(); stats.StaleHits != 1 {
+		t.Errorf("StaleHits = %d, want 1", stats.StaleHits)
+	}
+}
+
+func TestStaleGraceRefreshError(t *testing.T) {
+	clock := newFakeClock()
+	c := newTestCache(t, clock, WithStaleGrace(time.Hour))
+	c.Set("a", 1, time.Minute)
+	clock.Advance(2 * time.Minute)
+
+	errLoad := errors.New("FRED is down")
+	fail := func(context.Context) (int, time.Duration, error) {
+		return 0, 0, errLoad
+	}
+	if _, err := c.Reload(context.Background(), "a", fail); !errors.Is(err, errLoad) {
+		t.Fatalf("Reload() error = %v, want %v", err, errLoad)
+	}
+
+	// The stale value is kept, along with why it couldn't be refreshed
+	entry, err := c.GetOrLoadEntry(context.Background(), "a", fail)
+	if err != nil || entry.Value != 1 || !entry.Stale || !errors.Is(entry.RefreshErr, errLoad) {
+		t.Errorf("GetOrLoadEntry() = %+v, %v, want stale 1 with the refresh error", entry, err)
+	}
+}
+
+func TestStaleGraceExpires(t *testing.T) {
+	clock := newFakeClock()
+	c := newTestCache(t, clock, WithStaleGrace(time.Hour))
+	c.Set("a", 1, time.Minute)
+	clock.Advance(time.Minute + time.Hour + time.Second)
+
+	entry, err := c.GetOrLoadEntry(context.Background(), "a", func(context.Context) (int, time.Duration, error) {
+		return 2, time.Minute, nil
+	})
+	if err != nil || entry.Value != 2 || entry.Stale {
+		t.Errorf("GetOrLoadEntry() = %+v, %v, want a fresh load past the grace period", entry, err)
+	}
+	if stats := c.Stats(); stats.Expirations != 1 || stats.StaleHits != 0 {
+		t.Errorf("Stats() = %+v, want 1 expiration and no stale hits", stats)
+	}
+}
@@ -0,0 +1,242 @@
+package cache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// ErrNotStored is returned by a Store when it has nothing for a key
+var ErrNotStored = errors.New("cache: not stored")
+
+// Store is a persistent tier behind a Cache. Entries are written through on
+// Set and read through on a miss, so they survive restarts and eviction
+// from memory. They are deleted from the store when they expire or are
+// deleted from the cache.
+type Store interface {
+	Load(key string) ([]byte, error)
+	Save(key string, data []byte) error
+	Delete(key string) error
+}
+
+// WithStore persists entries to store. Keys are formatted with fmt.Sprint
+// and values encoded with encoding/gob, so V must be gob encodable.
+func WithStore(store Store) Option {
+	return func(c *config) {
+		c.store = store
+	}
+}
+
+// record is the stored form of an entry
+type record[V any] struct {
+	Value      V
+	UpdatedAt  time.Time
+	ExpireTime time.Time
+}
+
+// DiskStore is a Store that keeps each entry in its own file
+type DiskStore struct {
+	dir string
+}
+
+// NewDiskStore returns a store rooted at dir/version. Changing version,
+// e.g. when the format of cached values changes, leaves entries written
+// under other versions unread. The directory is created on the first Save.
+func NewDiskStore(dir, version string) *DiskStore {
+	return &DiskStore{dir: filepath.Join(dir, version)}
+}
+
+// Load returns the data saved for key
+func (s *DiskStore) Load(key string) ([]byte, error) {
+	data, err := os.ReadFile(s.file(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotStored
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cache file: %w", err)
+	}
+
+	return data, nil
+}
+
+// Save writes the data for key, replacing anything saved before
+func (s *DiskStore) Save(key string, data []byte) error {
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create cache dir: %w", err)
+	}
+
+	// Write to a temp file first so a crash never leaves a partial entry
+	tmp, err := os.CreateTemp(s.dir, "*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write cache file: %w", err)
+	}
+	// The temp file is already gone once it has been renamed
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write cache file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write cache file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.file(key)); err != nil {
+		return fmt.Errorf("failed to write cache file: %w", err)
+	}
+
+	return nil
+}
+
+// Delete removes the data for key, if any
+func (s *DiskStore) Delete(key string) error {
+	err := os.Remove(s.file(key))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to delete cache file: %w", err)
+	}
+
+	return nil
+}
+
+// file returns the file for a key. Keys are hashed since they may contain
+// characters that are not safe in file names.
+func (s *DiskStore) file(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(s.dir, hex.EncodeToString(sum[:])+".gob")
+}
+
+// persist writes an entry through to the store. Callers must hold storeMu.
+func (c *Cache[K, V]) persist(key K, itm *item[K, V]) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(record[V]{
+		Value:      itm.value,
+		UpdatedAt:  itm.updatedAt,
+		ExpireTime: itm.expireTime,
+	})
+	if err != nil {
+		log.Printf("failed to encode cache entry: %v", err)
+		return
+	}
+
+	if err := c.store.Save(fmt.Sprint(key), buf.Bytes()); err != nil {
+		log.Printf("failed to save cache entry: %v", err)
+	}
+}
+
+// restore reads an entry missing from memory back from the store, if it is
+// still within its stale grace period
+func (c *Cache[K, V]) restore(key K) {
+	if c.store == nil {
+		return
+	}
+
+	c.mu.Lock()
+	_, found := c.data[key]
+	c.mu.Unlock()
+	if found {
+		return
+	}
+
+	c.storeMu.Lock()
+	defer c.storeMu.Unlock()
+
+	// Check again now that Sets and deletes from the store are held off. The
+	// key may have been set since, or deleted with its stored copy still
+	// queued for deletion.
+	c.mu.Lock()
+	_, found = c.data[key]
+	removed := slices.Contains(c.removed, key)
+	c.mu.Unlock()
+	if found || removed {
+		return
+	}
+
+	data, err := c.store.Load(fmt.Sprint(key))
+	if errors.Is(err, ErrNotStored) {
+		return
+	}
+	if err != nil {
+		log.Printf("failed to load cache entry: %v", err)
+		return
+	}
+
+	var rec record[V]
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&rec); err != nil {
+		log.Printf("failed to decode cache entry: %v", err)
+		c.unstore(key)
+		return
+	}
+
+	if c.clock.Now().After(rec.ExpireTime.Add(c.grace)) {
+		c.unstore(key)
+		return
+	}
+
+	var size int64
+	if c.sizeOf != nil {
+		size = c.sizeOf(rec.Value)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.data[key] = c.lru.PushFront(&item[K, V]{
+		key:        key,
+		value:      rec.Value,
+		size:       size,
+		updatedAt:  rec.UpdatedAt,
+		expireTime: rec.ExpireTime,
+	})
+	c.bytes += size
+	c.stats.Restores++
+
+	c.evict()
+}
+
+// unstoreRemoved deletes the entries queued by remove from the store. Keys
+// set again since they were queued have already been dropped from the queue
+// by Set. Callers must not hold mu or storeMu.
+func (c *Cache[K, V]) unstoreRemoved() {
+	if c.store == nil {
+		return
+	}
+
+	// Most calls have nothing to delete, so don't wait on storeMu for them
+	c.mu.Lock()
+	pending := len(c.removed) > 0
+	c.mu.Unlock()
+	if !pending {
+		return
+	}
+
+	c.storeMu.Lock()
+	defer c.storeMu.Unlock()
+
+	c.mu.Lock()
+	keys := c.removed
+	c.removed = nil
+	c.mu.Unlock()
+
+	for _, key := range keys {
+		c.unstore(key)
+	}
+}
+
+// unstore deletes an entry from the store. Callers must hold storeMu.
+func (c *Cache[K, V]) unstore(key K) {
+	if c.store == nil {
+		return
+	}
+
+	if err := c.store.Delete(fmt.Sprint(key)); err != nil {
+		log.Printf("failed to delete cache entry: %v", err)
+	}
+}
@@ -0,0 +1,226 @@
+package cache
+
+import (
+	"errors"
+	"math/rand/v2"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+type series struct {
+	ID     string
+	Values []float64
+}
+
+func newStoreCache(t *testing.T, store Store, clock Clock) *Cache[string, series] {
+	t.Helper()
+
+	c := New[string, series](
+		WithStore(store),
+		WithClock(clock),
+		WithCleanupInterval(0),
+		WithStaleGrace(time.Hour),
+	)
+	t.Cleanup(c.Close)
+	return c
+}
+
+func TestDiskStoreRoundTrip(t *testing.T) {
+	clock := newFakeClock()
+	store := NewDiskStore(t.TempDir(), "1")
+
+	want := series{ID: "GDP", Values: []float64{1, 2.5, 3}}
+	newStoreCache(t, store, clock).Set("gdp", want, time.Minute)
+
+	// A new cache, as after a restart, reads the entry back from disk
+	c := newStoreCache(t, store, clock)
+	got, ok := c.Get("gdp")
+	if !ok {
+		t.Fatal("Get() missed an entry saved to the store")
+	}
+	if got.ID != want.ID || !slices.Equal(got.Values, want.Values) {
+		t.Errorf("Get() = %+v, want %+v", got, want)
+	}
+	if stats := c.Stats(); stats.Restores != 1 {
+		t.Errorf("Restores = %d, want 1", stats.Restores)
+	}
+
+	entries := c.Entries()
+	if len(entries) != 1 || !entries[0].ExpiresAt.Equal(clock.Now().Add(time.Minute)) {
+		t.Errorf("Entries() = %+v, want the saved expiry", entries)
+	}
+}
+
+func TestDiskStoreVersion(t *testing.T) {
+	clock := newFakeClock()
+	dir := t.TempDir()
+
+	newStoreCache(t, NewDiskStore(dir, "1"), clock).Set("gdp", series{ID: "GDP"}, time.Minute)
+
+	if _, ok := newStoreCache(t, NewDiskStore(dir, "2"), clock).Get("gdp"); ok {
+		t.Error("Get() restored an entry saved under another version")
+	}
+	if _, ok := newStoreCache(t, NewDiskStore(dir, "1"), clock).Get("gdp"); !ok {
+		t.Error("Get() missed an entry saved under the same version")
+	}
+}
+
+func TestDiskStoreCorrupt(t *testing.T) {
+	clock := newFakeClock()
+	store := NewDiskStore(t.TempDir(), "1")
+
+	newStoreCache(t, store, clock).Set("gdp", series{ID: "GDP"}, time.Minute)
+	if err := os.WriteFile(store.file("gdp"), []byte("not gob"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := newStoreCache(t, store, clock).Get("gdp"); ok {
+		t.Error("Get() returned a corrupt entry")
+	}
+	if _, err := store.Load("gdp"); !errors.Is(err, ErrNotStored) {
+		t.Errorf("Load() after a corrupt read = %v, want ErrNotStored", err)
+	}
+}
+
+func TestDiskStoreExpired(t *testing.T) {
+	clock := newFakeClock()
+	store := NewDiskStore(t.TempDir(), "1")
+
+	newStoreCache(t, store, clock).Set("gdp", series{ID: "GDP"}, time.Minute)
+	clock.Advance(time.Minute + time.Hour + time.Second)
+
+	if _, ok := newStoreCache(t, store, clock).Get("gdp"); ok {
+		t.Error("Get() restored an entry past its stale grace period")
+	}
+	if _, err := store.Load("gdp"); !errors.Is(err, ErrNotStored) {
+		t.Errorf("Load() after an expired read = %v, want ErrNotStored", err)
+	}
+}
+
+func TestDiskStoreDelete(t *testing.T) {
+	clock := newFakeClock()
+	store := NewDiskStore(t.TempDir(), "1")
+	c := newStoreCache(t, store, clock)
+
+	c.Set("gdp", series{ID: "GDP"}, time.Minute)
+	c.Set("cpi", series{ID: "CPI"}, time.Minute)
+	c.Delete("gdp")
+
+	if _, err := store.Load("gdp"); !errors.Is(err, ErrNotStored) {
+		t.Errorf("Load() after Delete() = %v, want ErrNotStored", err)
+	}
+	if _, err := store.Load("cpi"); err != nil {
+		t.Errorf("Load() of a kept entry = %v", err)
+	}
+
+	c.DeleteFunc(func(key string) bool { return key == "cpi" })
+	if _, err := store.Load("cpi"); !errors.Is(err, ErrNotStored) {
+		t.Errorf("Load() after DeleteFunc() = %v, want ErrNotStored", err)
+	}
+}
+
+// blockingStore blocks deletes until it is released
+type blockingStore struct {
+	Store
+	deleting chan struct{}
+	release  chan struct{}
+}
+
+func (s *blockingStore) Delete(key string) error {
+	s.deleting <- struct{}{}
+	<-s.release
+	return s.Store.Delete(key)
+}
+
+func TestStoreDeleteUnlocked(t *testing.T) {
+	clock := newFakeClock()
+	store := &blockingStore{
+		Store:    NewDiskStore(t.TempDir(), "1"),
+		deleting: make(chan struct{}),
+		release:  make(chan struct{}),
+	}
+	c := newStoreCache(t, store, clock)
+	c.Set("gdp", series{ID: "GDP"}, time.Minute)
+	c.Set("cpi", series{ID: "CPI"}, time.Minute)
+
+	done := make(chan struct{})
+	go func() {
+		c.Delete("gdp")
+		close(done)
+	}()
+	<-store.deleting
+
+	// Readers aren't held up by a slow store
+	if _, ok := c.Get("cpi"); !ok {
+		t.Error("Get() missed an entry while another was being deleted")
+	}
+
+	close(store.release)
+	<-done
+}
+
+func TestStoreKeepsEvicted(t *testing.T) {
+	clock := newFakeClock()
+	store := NewDiskStore(t.TempDir(), "1")
+	c := New[string, series](WithStore(store), WithClock(clock), WithCleanupInterval(0), WithMaxEntries(1))
+	t.Cleanup(c.Close)
+
+	c.Set("gdp", series{ID: "GDP"}, time.Minute)
+	c.Set("cpi", series{ID: "CPI"}, time.Minute)
+	if got := keys(c); !slices.Equal(got, []string{"cpi"}) {
+		t.Fatalf("keys = %v, want gdp evicted", got)
+	}
+
+	// Eviction only frees memory, so the entry is read back from the store
+	if _, err := store.Load("gdp"); err != nil {
+		t.Errorf("Load() of an evicted entry = %v", err)
+	}
+	got, ok := c.Get("gdp")
+	if !ok || got.ID != "GDP" {
+		t.Errorf("Get() of an evicted entry = %+v, %v, want it restored", got, ok)
+	}
+	if stats := c.Stats(); stats.Evictions != 2 || stats.Restores != 1 {
+		t.Errorf("Stats() = %+v, want 2 evictions and 1 restore", stats)
+	}
+
+	// Restoring gdp evicted cpi, which is still stored too
+	if _, err := store.Load("cpi"); err != nil {
+		t.Errorf("Load() of an evicted entry = %v", err)
+	}
+}
+
+// slowStore takes a random time to save, so concurrent saves finish out of
+// order
+type slowStore struct {
+	Store
+}
+
+func (s slowStore) Save(key string, data []byte) error {
+	time.Sleep(rand.N(time.Millisecond))
+	return s.Store.Save(key, data)
+}
+
+func TestStoreConcurrentSets(t *testing.T) {
+	clock := newFakeClock()
+	store := slowStore{NewDiskStore(t.TempDir(), "1")}
+	c := newStoreCache(t, store, clock)
+
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Go(func() {
+			c.Set("gdp", series{ID: strconv.Itoa(i)}, time.Minute)
+		})
+	}
+	wg.Wait()
+
+	// The store has the value that was set last, as memory does
+	want, _ := c.Get("gdp")
+	got, ok := newStoreCache(t, store, clock).Get("gdp")
+	if !ok || got.ID != want.ID {
+		t.Errorf("stored value = %+v, %v, want %+v", got, ok, want)
+	}
+}
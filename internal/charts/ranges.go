@@ -6,7 +6,12 @@ import "time"
 // Returns nil for "max" to fetch all available data.
 // Used by chart tools to determine observation_start for FRED queries.
 func CalculateRangeStart(rangeParam string) *time.Time {
-	now := time.Now()
+	return CalculateRangeStartAt(rangeParam, time.Now())
+}
+
+// CalculateRangeStartAt is like CalculateRangeStart but counts back from the
+// given date instead of today, e.g. for point-in-time (vintage) charts.
+func CalculateRangeStartAt(rangeParam string, now time.Time) *time.Time {
 	switch rangeParam {
 	case "1y":
 		t := now.AddDate(-1, 0, 0)
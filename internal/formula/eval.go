@@ -0,0 +1,399 @@
+package formula
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/shanehull/shanehull.com/internal/fred"
+)
+
+// maxPeriods bounds lags and rolling windows
+const maxPeriods = 1000
+
+// function is a function that can be called in a formula. Windowed
+// functions take a rolling window length as their second argument.
+type function struct {
+	name      string
+	windowed  bool
+	minWindow int
+	apply     func(s *series, window int) *series
+}
+
+var functions = map[string]*function{
+	"yoy":  {name: "yoy", apply: yearOverYear},
+	"abs":  {name: "abs", apply: pointwise(math.Abs)},
+	"log":  {name: "log", apply: pointwise(math.Log)},
+	"mean": {name: "mean", windowed: true, minWindow: 1, apply: rolling(newMeanWindow)},
+	"sum":  {name: "sum", windowed: true, minWindow: 1, apply: rolling(newSumWindow)},
+	"min":  {name: "min", windowed: true, minWindow: 1, apply: rolling(newMinWindow)},
+	"max":  {name: "max", windowed: true, minWindow: 1, apply: rolling(newMaxWindow)},
+	"std":  {name: "std", windowed: true, minWindow: 2, apply: rolling(newStdWindow)},
+}
+
+// series is a time series in ascending date order
+type series struct {
+	dates  []time.Time
+	values []float64
+}
+
+func (s *series) len() int {
+	return len(s.dates)
+}
+
+// value is the result of evaluating a node: a series, or a constant if
+// series is nil
+type value struct {
+	series   *series
+	constant float64
+}
+
+// Eval evaluates the formula over the given series, keyed by ID, which must
+// include every series the formula references. Missing observations (NaN)
+// are carried through as gaps; dates where the result is infinite, e.g.
+// after dividing by zero, are dropped.
+func (e *Expr) Eval(data map[string][]fred.DataPoint) ([]fred.DataPoint, error) {
+	v, err := eval(e.root, data)
+	if err != nil {
+		return nil, err
+	}
+
+	points := make([]fred.DataPoint, 0, v.series.len())
+	for i, date := range v.series.dates {
+		val := v.series.values[i]
+		if math.IsInf(val, 0) {
+			continue
+		}
+		points = append(points, fred.DataPoint{
+			Date:    date,
+			Value:   val,
+			Missing: math.IsNaN(val),
+		})
+	}
+	return points, nil
+}
+
+func eval(n node, data map[string][]fred.DataPoint) (value, error) {
+	switch n := n.(type) {
+	case *numberNode:
+		return value{constant: n.value}, nil
+
+	case *seriesNode:
+		points, ok := data[n.id]
+		if !ok {
+			return value{}, fmt.Errorf("formula: no data for series %s", n.id)
+		}
+		s := &series{
+			dates:  make([]time.Time, len(points)),
+			values: make([]float64, len(points)),
+		}
+		for i, p := range points {
+			s.dates[i] = p.Date
+			s.values[i] = p.Value
+		}
+		return value{series: s}, nil
+
+	case *unaryNode:
+		x, err := eval(n.x, data)
+		if err != nil {
+			return value{}, err
+		}
+		if x.series == nil {
+			return value{constant: -x.constant}, nil
+		}
+		return value{series: pointwise(func(v float64) float64 { return -v })(x.series, 0)}, nil
+
+	case *binaryNode:
+		x, err := eval(n.x, data)
+		if err != nil {
+			return value{}, err
+		}
+		y, err := eval(n.y, data)
+		if err != nil {
+			return value{}, err
+		}
+		return combine(n.op, x, y), nil
+
+	case *lagNode:
+		x, err := eval(n.x, data)
+		if err != nil {
+			return value{}, err
+		}
+		if x.series == nil {
+			return x, nil
+		}
+		return value{series: lag(x.series, n.n)}, nil
+
+	case *callNode:
+		x, err := eval(n.x, data)
+		if err != nil {
+			return value{}, err
+		}
+		if x.series == nil {
+			return value{}, fmt.Errorf("formula: %s needs a series, not a constant", n.fn.name)
+		}
+		return value{series: n.fn.apply(x.series, n.window)}, nil
+	}
+
+	return value{}, fmt.Errorf("formula: unknown node %T", n)
+}
+
+// combine applies a binary operator. Two series are joined on the dates
+// both have an observation for.
+func combine(op byte, x, y value) value {
+	apply := func(a, b float64) float64 {
+		switch op {
+		case '+':
+			return a + b
+		case '-':
+			return a - b
+		case '*':
+			return a * b
+		case '/':
+			return a / b
+		default:
+			return math.Pow(a, b)
+		}
+	}
+
+	switch {
+	case x.series == nil && y.series == nil:
+		return value{constant: apply(x.constant, y.constant)}
+	case y.series == nil:
+		return value{series: pointwise(func(v float64) float64 { return apply(v, y.constant) })(x.series, 0)}
+	case x.series == nil:
+		return value{series: pointwise(func(v float64) float64 { return apply(x.constant, v) })(y.series, 0)}
+	}
+
+	out := &series{}
+	i, j := 0, 0
+	for i < x.series.len() && j < y.series.len() {
+		a, b := x.series.dates[i], y.series.dates[j]
+		switch {
+		case a.Before(b):
+			i++
+		case b.Before(a):
+			j++
+		default:
+			out.dates = append(out.dates, a)
+			out.values = append(out.values, apply(x.series.values[i], y.series.values[j]))
+			i++
+			j++
+		}
+	}
+	return value{series: out}
+}
+
+// pointwise returns a function that applies fn to each value of a series
+func pointwise(fn func(float64) float64) func(*series, int) *series {
+	return func(s *series, _ int) *series {
+		out := &series{dates: s.dates, values: make([]float64, s.len())}
+		for i, v := range s.values {
+			out.values[i] = fn(v)
+		}
+		return out
+	}
+}
+
+// lag shifts the values of a series n observations later, dropping the
+// first n dates
+func lag(s *series, n int) *series {
+	if n >= s.len() {
+		return &series{}
+	}
+	return &series{
+		dates:  s.dates[n:],
+		values: s.values[:s.len()-n],
+	}
+}
+
+// yearOverYear is the percent change from the same date a year earlier.
+// Dates without an observation a year earlier are dropped.
+func yearOverYear(s *series, _ int) *series {
+	byDate := make(map[string]float64, s.len())
+	for i, date := range s.dates {
+		byDate[date.Format("2006-01-02")] = s.values[i]
+	}
+
+	out := &series{}
+	for i, date := range s.dates {
+		prev, ok := byDate[date.AddDate(-1, 0, 0).Format("2006-01-02")]
+		if !ok {
+			continue
+		}
+		out.dates = append(out.dates, date)
+		out.values = append(out.values, ((s.values[i]/prev)-1)*100)
+	}
+	return out
+}
+
+// window is a rolling statistic, updated as values enter and leave the
+// window so that each step costs the same whatever the window length. The
+// index of each value in the series is passed along with it.
+type window interface {
+	add(i int, v float64)
+	remove(i int, v float64)
+	value() float64
+}
+
+// rolling returns a function that applies a rolling statistic to each
+// trailing window of a series. Dates before the first full window are
+// dropped, and windows with a missing value are missing.
+func rolling(newWindow func() window) func(*series, int) *series {
+	return func(s *series, n int) *series {
+		if n > s.len() {
+			return &series{}
+		}
+
+		out := &series{
+			dates:  s.dates[n-1:],
+			values: make([]float64, s.len()-n+1),
+		}
+		w := newWindow()
+		missing := 0
+		for i, v := range s.values {
+			if i >= n {
+				if old := s.values[i-n]; math.IsNaN(old) {
+					missing--
+				} else {
+					w.remove(i-n, old)
+				}
+			}
+			if math.IsNaN(v) {
+				missing++
+			} else {
+				w.add(i, v)
+			}
+
+			if i < n-1 {
+				continue
+			}
+			// Rebuild the window once per window length, so that rounding
+			// errors from removed values don't accumulate. This keeps the
+			// cost per step constant.
+			if start := i - n + 1; start%n == 0 && start > 0 {
+				w = newWindow()
+				for j, v := range s.values[start : i+1] {
+					if !math.IsNaN(v) {
+						w.add(start+j, v)
+					}
+				}
+			}
+			if missing > 0 {
+				out.values[i-n+1] = math.NaN()
+			} else {
+				out.values[i-n+1] = w.value()
+			}
+		}
+		return out
+	}
+}
+
+// sumWindow is the rolling sum
+type sumWindow struct {
+	n   int
+	sum float64
+}
+
+func newSumWindow() window { return &sumWindow{} }
+
+func (w *sumWindow) add(_ int, v float64) {
+	w.n++
+	w.sum += v
+}
+
+func (w *sumWindow) remove(_ int, v float64) {
+	w.n--
+	w.sum -= v
+}
+
+func (w *sumWindow) value() float64 { return w.sum }
+
+// meanWindow is the rolling mean
+type meanWindow struct {
+	sumWindow
+}
+
+func newMeanWindow() window { return &meanWindow{} }
+
+func (w *meanWindow) value() float64 { return w.sum / float64(w.n) }
+
+// stdWindow is the rolling sample standard deviation, kept with Welford's
+// algorithm, which unlike a running sum of squares doesn't lose precision
+// when the values are large relative to their spread
+type stdWindow struct {
+	n    int
+	mean float64
+	m2   float64
+}
+
+func newStdWindow() window { return &stdWindow{} }
+
+func (w *stdWindow) add(_ int, v float64) {
+	w.n++
+	d := v - w.mean
+	w.mean += d / float64(w.n)
+	w.m2 += d * (v - w.mean)
+}
+
+func (w *stdWindow) remove(_ int, v float64) {
+	w.n--
+	if w.n == 0 {
+		w.mean, w.m2 = 0, 0
+		return
+	}
+	d := v - w.mean
+	w.mean -= d / float64(w.n)
+	w.m2 -= d * (v - w.mean)
+}
+
+func (w *stdWindow) value() float64 {
+	// Rounding can leave m2 just below zero for a constant window
+	return math.Sqrt(max(w.m2, 0) / float64(w.n-1))
+}
+
+// extremeWindow is the rolling minimum or maximum, kept as a deque of the
+// values that can still become the extreme: each is more extreme than
+// those added before it, and the front is the extreme of the window
+type extremeWindow struct {
+	indexes []int
+	values  []float64
+
+	// before reports whether a is more extreme than b
+	before func(a, b float64) bool
+}
+
+func newMinWindow() window {
+	return &extremeWindow{before: func(a, b float64) bool { return a <= b }}
+}
+
+func newMaxWindow() window {
+	return &extremeWindow{before: func(a, b float64) bool { return a >= b }}
+}
+
+func (w *extremeWindow) add(i int, v float64) {
+	for len(w.values) > 0 && w.before(v, w.values[len(w.values)-1]) {
+		w.indexes = w.indexes[:len(w.indexes)-1]
+		w.values = w.values[:len(w.values)-1]
+	}
+	w.indexes = append(w.indexes, i)
+	w.values = append(w.values, v)
+}
+
+func (w *extremeWindow) remove(i int, _ float64) {
+	if len(w.indexes) > 0 && w.indexes[0] == i {
+		w.indexes = w.indexes[1:]
+		w.values = w.values[1:]
+	}
+}
+
+func (w *extremeWindow) value() float64 { return w.values[0] }
+
+func hasNaN(values []float64) bool {
+	for _, v := range values {
+		if math.IsNaN(v) {
+			return true
+		}
+	}
+	return false
+}
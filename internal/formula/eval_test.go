@@ -0,0 +1,194 @@
+package formula_test
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/shanehull/shanehull.com/internal/formula"
+	"github.com/shanehull/shanehull.com/internal/fred"
+)
+
+// monthly returns monthly observations from January 2020 after skipping
+// the first skip months. NaN values are missing observations.
+func monthly(skip int, values ...float64) []fred.DataPoint {
+	points := make([]fred.DataPoint, len(values))
+	for i, v := range values {
+		points[i] = fred.DataPoint{
+			Date:    time.Date(2020, time.Month(1+skip+i), 1, 0, 0, 0, 0, time.UTC),
+			Value:   v,
+			Missing: math.IsNaN(v),
+		}
+	}
+	return points
+}
+
+func TestEval(t *testing.T) {
+	nan := math.NaN()
+	data := map[string][]fred.DataPoint{
+		"A":    monthly(0, 1, 2, 3, 4),
+		"B":    monthly(1, 2, 0, 8, 16),
+		"GAPS": monthly(0, 1, nan, 3, 4),
+		"YEAR": monthly(0, 100, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 110, 2),
+	}
+
+	tests := []struct {
+		src  string
+		want []fred.DataPoint
+	}{
+		{"A + 2 * 3", monthly(0, 7, 8, 9, 10)},
+		{"(A + 2) * 3", monthly(0, 9, 12, 15, 18)},
+		{"A - 1 - 1", monthly(0, -1, 0, 1, 2)},
+		{"8 / 2 / A", monthly(0, 4, 2, 4.0/3, 1)},
+		// ^ is right associative and binds tighter than negation and *
+		{"2 ^ 3 ^ 2 * A", monthly(0, 512, 1024, 1536, 2048)},
+		{"-A ^ 2", monthly(0, -1, -4, -9, -16)},
+		{"A ^ -1", monthly(0, 1, 0.5, 1.0/3, 0.25)},
+		// Series are joined on their common dates
+		{"A + B", monthly(1, 4, 3, 12)},
+		// Dividing by zero drops the date
+		{"A / B", []fred.DataPoint{monthly(1, 1)[0], monthly(3, 0.5)[0]}},
+		{"A - A[-1]", monthly(1, 1, 1, 1)},
+		{"A[-2]", monthly(2, 1, 2)},
+		{"A[-4]", []fred.DataPoint{}},
+		{"A[-1][-1]", monthly(2, 1, 2)},
+		{"yoy(YEAR)", monthly(12, 10, 100)},
+		{"mean(A, 2)", monthly(1, 1.5, 2.5, 3.5)},
+		{"sum(A, 3)", monthly(2, 6, 9)},
+		{"min(B, 2)", monthly(2, 0, 0, 8)},
+		{"max(B, 2)", monthly(2, 2, 8, 16)},
+		{"std(A, 2)", monthly(1, math.Sqrt(0.5), math.Sqrt(0.5), math.Sqrt(0.5))},
+		{"mean(A, 5)", []fred.DataPoint{}},
+		{"abs(-A)", monthly(0, 1, 2, 3, 4)},
+		{"log(B * 0 + 1)", monthly(1, 0, 0, 0, 0)},
+		// Missing observations carry through as gaps
+		{"GAPS * 2", monthly(0, 2, nan, 6, 8)},
+		{"mean(GAPS, 2)", monthly(1, nan, nan, 3.5)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			expr, err := formula.Parse(tt.src)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			got, err := expr.Eval(data)
+			if err != nil {
+				t.Fatalf("Eval() error = %v", err)
+			}
+			if !equalPoints(got, tt.want) {
+				t.Errorf("Eval() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	data := map[string][]fred.DataPoint{"A": monthly(0, 1, 2)}
+
+	for _, src := range []string{"A + B", "A + mean(2, 2)", "yoy(1) * A"} {
+		expr, err := formula.Parse(src)
+		if err != nil {
+			t.Fatalf("Parse(%q) error = %v", src, err)
+		}
+		if _, err := expr.Eval(data); err == nil {
+			t.Errorf("Eval(%q) error = nil, want an error", src)
+		}
+	}
+}
+
+func equalPoints(a, b []fred.DataPoint) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].Date.Equal(b[i].Date) || a[i].Missing != b[i].Missing {
+			return false
+		}
+		if !a[i].Missing && math.Abs(a[i].Value-b[i].Value) > 1e-9 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestRollingMatchesNaive(t *testing.T) {
+	// Large values with a small spread, and some gaps
+	values := make([]float64, 500)
+	for i := range values {
+		values[i] = 1e6 + math.Sin(float64(i)*0.7)*10 + float64(i%7)
+		if i%37 == 5 {
+			values[i] = math.NaN()
+		}
+	}
+	data := map[string][]fred.DataPoint{"A": monthly(0, values...)}
+
+	naive := map[string]func(window []float64) float64{
+		"sum": func(w []float64) float64 {
+			var sum float64
+			for _, v := range w {
+				sum += v
+			}
+			return sum
+		},
+		"min": func(w []float64) float64 {
+			m := w[0]
+			for _, v := range w {
+				m = math.Min(m, v)
+			}
+			return m
+		},
+		"max": func(w []float64) float64 {
+			m := w[0]
+			for _, v := range w {
+				m = math.Max(m, v)
+			}
+			return m
+		},
+	}
+	naive["mean"] = func(w []float64) float64 {
+		return naive["sum"](w) / float64(len(w))
+	}
+	naive["std"] = func(w []float64) float64 {
+		mean := naive["mean"](w)
+		var ss float64
+		for _, v := range w {
+			ss += (v - mean) * (v - mean)
+		}
+		return math.Sqrt(ss / float64(len(w)-1))
+	}
+
+	for name, fn := range naive {
+		for _, n := range []int{2, 3, 30, 499} {
+			expr, err := formula.Parse(fmt.Sprintf("%s(A, %d)", name, n))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := expr.Eval(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want := make([]float64, 0, len(values)-n+1)
+			for i := n; i <= len(values); i++ {
+				window := values[i-n : i]
+				if slices.ContainsFunc(window, math.IsNaN) {
+					want = append(want, math.NaN())
+					continue
+				}
+				want = append(want, fn(window))
+			}
+
+			if len(got) != len(want) {
+				t.Fatalf("%s(A, %d) has %d points, want %d", name, n, len(got), len(want))
+			}
+			for i, p := range got {
+				if p.Missing != math.IsNaN(want[i]) || !p.Missing && math.Abs(p.Value-want[i]) > 1e-6*math.Max(1, math.Abs(want[i])) {
+					t.Errorf("%s(A, %d)[%d] = %v, want %v", name, n, i, p.Value, want[i])
+					break
+				}
+			}
+		}
+	}
+}
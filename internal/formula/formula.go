@@ -0,0 +1,419 @@
+// Package formula parses and evaluates arithmetic over FRED series, such as
+// "(NCBEILQ027S / 1000) / GDP * 100" or "TB3MS - yoy(CPIAUCNS)".
+//
+// A formula is built from numbers, series IDs, the operators + - * / and ^,
+// parentheses, lags and functions:
+//
+//	CPIAUCNS[-12]   the value 12 observations earlier
+//	yoy(x)          percent change from the same date a year earlier
+//	abs(x), log(x)  absolute value and natural log
+//	mean(x, n)      rolling mean over the last n observations
+//	sum(x, n)       rolling sum over the last n observations
+//	min(x, n)       rolling minimum over the last n observations
+//	max(x, n)       rolling maximum over the last n observations
+//	std(x, n)       rolling standard deviation over the last n observations
+//
+// Operations on two series keep the dates both have an observation for.
+package formula
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Expr is a parsed formula
+type Expr struct {
+	src    string
+	root   node
+	series []string
+}
+
+// ErrNoSeries is returned by Parse for a formula that only uses constants
+var ErrNoSeries = errors.New("formula: no series referenced")
+
+// SyntaxError reports where a formula failed to parse
+type SyntaxError struct {
+	Pos int
+	Msg string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("formula: %s at position %d", e.Msg, e.Pos+1)
+}
+
+// Parse parses a formula. It must reference at least one series.
+func Parse(src string) (*Expr, error) {
+	p := &parser{src: src}
+	p.next()
+
+	root, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokEOF {
+		return nil, p.errorf("unexpected %s", p.tok)
+	}
+
+	var series []string
+	walk(root, func(n node) {
+		if s, ok := n.(*seriesNode); ok && !slices.Contains(series, s.id) {
+			series = append(series, s.id)
+		}
+	})
+	if len(series) == 0 {
+		return nil, ErrNoSeries
+	}
+
+	return &Expr{src: src, root: root, series: series}, nil
+}
+
+// String returns the formula as it was written
+func (e *Expr) String() string {
+	return e.src
+}
+
+// Series returns the IDs of the series the formula references, in the order
+// they first appear
+func (e *Expr) Series() []string {
+	return slices.Clone(e.series)
+}
+
+// Size returns the number of operations in the formula
+func (e *Expr) Size() int {
+	n := 0
+	walk(e.root, func(node) { n++ })
+	return n
+}
+
+// Lookback returns the total length of the formula's lags and rolling
+// windows. Each observation of the result depends on about that many
+// earlier observations of its series, which nested windows consume without
+// adding anything useful.
+func (e *Expr) Lookback() int {
+	n := 0
+	walk(e.root, func(nd node) {
+		switch nd := nd.(type) {
+		case *lagNode:
+			n += nd.n
+		case *callNode:
+			n += nd.window
+		}
+	})
+	return n
+}
+
+type node interface{}
+
+type numberNode struct {
+	value float64
+}
+
+type seriesNode struct {
+	id string
+}
+
+type unaryNode struct {
+	op byte
+	x  node
+}
+
+type binaryNode struct {
+	op   byte
+	x, y node
+}
+
+type lagNode struct {
+	x node
+	n int
+}
+
+type callNode struct {
+	fn     *function
+	x      node
+	window int
+}
+
+// walk calls fn for n and each node below it
+func walk(n node, fn func(node)) {
+	fn(n)
+	switch n := n.(type) {
+	case *unaryNode:
+		walk(n.x, fn)
+	case *binaryNode:
+		walk(n.x, fn)
+		walk(n.y, fn)
+	case *lagNode:
+		walk(n.x, fn)
+	case *callNode:
+		walk(n.x, fn)
+	}
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokNumber
+	tokIdent
+	tokOp
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func (t token) String() string {
+	if t.kind == tokEOF {
+		return "end of formula"
+	}
+	return strconv.Quote(t.text)
+}
+
+type parser struct {
+	src string
+	pos int
+	tok token
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	return &SyntaxError{Pos: p.tok.pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+// next scans the next token into p.tok. A character that can't start a
+// token is returned as an operator and rejected by the parser.
+func (p *parser) next() {
+	for p.pos < len(p.src) && unicode.IsSpace(rune(p.src[p.pos])) {
+		p.pos++
+	}
+	start := p.pos
+	if p.pos >= len(p.src) {
+		p.tok = token{kind: tokEOF, pos: start}
+		return
+	}
+
+	c := p.src[p.pos]
+	switch {
+	case isDigit(c) || c == '.':
+		for p.pos < len(p.src) && (isDigit(p.src[p.pos]) || p.src[p.pos] == '.') {
+			p.pos++
+		}
+		// Exponent, e.g. 1e3 or 2.5E-4
+		if p.pos < len(p.src) && (p.src[p.pos] == 'e' || p.src[p.pos] == 'E') {
+			end := p.pos + 1
+			if end < len(p.src) && (p.src[end] == '+' || p.src[end] == '-') {
+				end++
+			}
+			if end < len(p.src) && isDigit(p.src[end]) {
+				for end < len(p.src) && isDigit(p.src[end]) {
+					end++
+				}
+				p.pos = end
+			}
+		}
+		p.tok = token{kind: tokNumber, text: p.src[start:p.pos], pos: start}
+	case isIdentStart(c):
+		for p.pos < len(p.src) && (isIdentStart(p.src[p.pos]) || isDigit(p.src[p.pos])) {
+			p.pos++
+		}
+		p.tok = token{kind: tokIdent, text: p.src[start:p.pos], pos: start}
+	default:
+		p.pos++
+		p.tok = token{kind: tokOp, text: p.src[start:p.pos], pos: start}
+	}
+}
+
+func (p *parser) is(op string) bool {
+	return p.tok.kind == tokOp && p.tok.text == op
+}
+
+func (p *parser) expect(op string) error {
+	if !p.is(op) {
+		return p.errorf("expected %q, found %s", op, p.tok)
+	}
+	p.next()
+	return nil
+}
+
+// expr parses a sum: term (('+' | '-') term)*
+func (p *parser) expr() (node, error) {
+	x, err := p.term()
+	if err != nil {
+		return nil, err
+	}
+	for p.is("+") || p.is("-") {
+		op := p.tok.text[0]
+		p.next()
+		y, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+		x = &binaryNode{op: op, x: x, y: y}
+	}
+	return x, nil
+}
+
+// term parses a product: unary (('*' | '/') unary)*
+func (p *parser) term() (node, error) {
+	x, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for p.is("*") || p.is("/") {
+		op := p.tok.text[0]
+		p.next()
+		y, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		x = &binaryNode{op: op, x: x, y: y}
+	}
+	return x, nil
+}
+
+// unary parses a negation or a power: '-' unary | postfix ('^' unary)?
+func (p *parser) unary() (node, error) {
+	if p.is("-") || p.is("+") {
+		op := p.tok.text[0]
+		p.next()
+		x, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		if op == '+' {
+			return x, nil
+		}
+		return &unaryNode{op: op, x: x}, nil
+	}
+
+	x, err := p.postfix()
+	if err != nil {
+		return nil, err
+	}
+	if p.is("^") {
+		p.next()
+		y, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		x = &binaryNode{op: '^', x: x, y: y}
+	}
+	return x, nil
+}
+
+// postfix parses lags: primary ('[' '-' integer ']')*
+func (p *parser) postfix() (node, error) {
+	x, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	for p.is("[") {
+		p.next()
+		if err := p.expect("-"); err != nil {
+			return nil, p.errorf("lags must be negative, e.g. [-12]")
+		}
+		n, err := p.integer("lag")
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect("]"); err != nil {
+			return nil, err
+		}
+		x = &lagNode{x: x, n: n}
+	}
+	return x, nil
+}
+
+// primary parses a number, a series, a function call or a parenthesized
+// expression
+func (p *parser) primary() (node, error) {
+	switch {
+	case p.tok.kind == tokNumber:
+		v, err := strconv.ParseFloat(p.tok.text, 64)
+		if err != nil {
+			return nil, p.errorf("invalid number %s", p.tok)
+		}
+		p.next()
+		return &numberNode{value: v}, nil
+
+	case p.tok.kind == tokIdent:
+		name := p.tok
+		p.next()
+		if !p.is("(") {
+			return &seriesNode{id: name.text}, nil
+		}
+		return p.call(name)
+
+	case p.is("("):
+		p.next()
+		x, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return x, nil
+	}
+
+	return nil, p.errorf("unexpected %s", p.tok)
+}
+
+// call parses the arguments of a function call, after its name
+func (p *parser) call(name token) (node, error) {
+	fn, ok := functions[strings.ToLower(name.text)]
+	if !ok {
+		return nil, &SyntaxError{Pos: name.pos, Msg: fmt.Sprintf("unknown function %q", name.text)}
+	}
+	p.next() // (
+
+	x, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+
+	c := &callNode{fn: fn, x: x}
+	if fn.windowed {
+		if err := p.expect(","); err != nil {
+			return nil, p.errorf("%s takes a series and a window, e.g. %s(x, 12)", fn.name, fn.name)
+		}
+		pos := p.tok.pos
+		if c.window, err = p.integer("window"); err != nil {
+			return nil, err
+		}
+		if c.window < fn.minWindow {
+			return nil, &SyntaxError{Pos: pos, Msg: fmt.Sprintf("%s window must be at least %d", fn.name, fn.minWindow)}
+		}
+	}
+
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// integer parses a positive integer literal
+func (p *parser) integer(what string) (int, error) {
+	if p.tok.kind != tokNumber {
+		return 0, p.errorf("expected %s length, found %s", what, p.tok)
+	}
+	n, err := strconv.Atoi(p.tok.text)
+	if err != nil || n < 1 || n > maxPeriods {
+		return 0, p.errorf("%s length must be a whole number from 1 to %d", what, maxPeriods)
+	}
+	p.next()
+	return n, nil
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
@@ -0,0 +1,110 @@
+package formula_test
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/shanehull/shanehull.com/internal/formula"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		src    string
+		series []string
+	}{
+		{"GDP", []string{"GDP"}},
+		{"(NCBEILQ027S / 1000) / GDP * 100", []string{"NCBEILQ027S", "GDP"}},
+		{"TB3MS - yoy(CPIAUCNS)", []string{"TB3MS", "CPIAUCNS"}},
+		{"GDP / GDP[-4] - 1", []string{"GDP"}},
+		{"GDP[-1][-2]", []string{"GDP"}},
+		{"-GDP ^ 2", []string{"GDP"}},
+		{"+GDP", []string{"GDP"}},
+		{"1e3 * GDP + 2.5E-4 * .5", []string{"GDP"}},
+		{"MEAN(DGS10, 12) - Std(DGS2, 12)", []string{"DGS10", "DGS2"}},
+		{"abs(log(my_series2))", []string{"my_series2"}},
+		{"\tGDP\n+\nCPIAUCNS ", []string{"GDP", "CPIAUCNS"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			expr, err := formula.Parse(tt.src)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if got := expr.Series(); !slices.Equal(got, tt.series) {
+				t.Errorf("Series() = %v, want %v", got, tt.series)
+			}
+			if expr.String() != tt.src {
+				t.Errorf("String() = %q, want %q", expr.String(), tt.src)
+			}
+		})
+	}
+}
+
+func TestParseSyntaxError(t *testing.T) {
+	tests := []struct {
+		src string
+		pos int
+		msg string
+	}{
+		{"", 0, "unexpected end of formula"},
+		{"GDP +", 5, "unexpected end of formula"},
+		{"GDP + * 2", 6, `unexpected "*"`},
+		{"GDP # 2", 4, `unexpected "#"`},
+		{"GDP)", 3, `unexpected ")"`},
+		{"(GDP", 4, `expected ")", found end of formula`},
+		{"1..2 + GDP", 0, `invalid number "1..2"`},
+		{"GDP + foo(GDP)", 6, `unknown function "foo"`},
+		{"mean(GDP)", 8, "mean takes a series and a window, e.g. mean(x, 12)"},
+		{"mean(GDP, x)", 10, `expected window length, found "x"`},
+		{"mean(GDP, 0)", 10, "window length must be a whole number from 1 to 1000"},
+		{"std(GDP, 1)", 9, "std window must be at least 2"},
+		{"yoy(GDP, 12)", 7, `expected ")", found ","`},
+		{"GDP[12]", 4, "lags must be negative, e.g. [-12]"},
+		{"GDP[-1.5]", 5, "lag length must be a whole number from 1 to 1000"},
+		{"GDP[-1001]", 5, "lag length must be a whole number from 1 to 1000"},
+		{"GDP[-1", 6, `expected "]", found end of formula`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			_, err := formula.Parse(tt.src)
+			var syntaxErr *formula.SyntaxError
+			if !errors.As(err, &syntaxErr) {
+				t.Fatalf("Parse() error = %v, want a *SyntaxError", err)
+			}
+			if syntaxErr.Pos != tt.pos || syntaxErr.Msg != tt.msg {
+				t.Errorf("Parse() error at %d: %s, want at %d: %s", syntaxErr.Pos, syntaxErr.Msg, tt.pos, tt.msg)
+			}
+		})
+	}
+}
+
+func TestParseNoSeries(t *testing.T) {
+	for _, src := range []string{"1", "2 * (3 + 4)", "-1[-1]"} {
+		if _, err := formula.Parse(src); !errors.Is(err, formula.ErrNoSeries) {
+			t.Errorf("Parse(%q) error = %v, want ErrNoSeries", src, err)
+		}
+	}
+}
+
+func TestLookback(t *testing.T) {
+	tests := []struct {
+		src  string
+		want int
+	}{
+		{"GDP", 0},
+		{"yoy(GDP)", 0},
+		{"GDP[-4]", 4},
+		{"mean(GDP, 12) - mean(GDP[-1], 12)", 25},
+		{"std(std(GDP, 999), 999)", 1998},
+	}
+	for _, tt := range tests {
+		expr, err := formula.Parse(tt.src)
+		if err != nil {
+			t.Fatalf("Parse(%q) error = %v", tt.src, err)
+		}
+		if got := expr.Lookback(); got != tt.want {
+			t.Errorf("Lookback(%q) = %d, want %d", tt.src, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package fred
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	defaultBaseURL = "https://api.stlouisfed.org/fred"
+	defaultTimeout = 30 * time.Second
+)
+
+// Client is a FRED API client. The zero value is not usable; create one
+// with NewClient.
+type Client struct {
+	baseURL    string
+	apiKey     string
+	httpClient *http.Client
+	timeout    time.Duration
+	retry      RetryPolicy
+	limiter    *Limiter
+	mode       Mode
+	snapshots  *SnapshotStore
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithBaseURL overrides the FRED API base URL, e.g. to point the client at a
+// local stand-in. The URL should not include the endpoint path.
+func WithBaseURL(baseURL string) Option {
+	return func(c *Client) {
+		c.baseURL = strings.TrimSuffix(baseURL, "/")
+	}
+}
+
+// WithAPIKey sets the FRED API key. Defaults to the FRED_API_KEY
+// environment variable.
+func WithAPIKey(apiKey string) Option {
+	return func(c *Client) {
+		c.apiKey = apiKey
+	}
+}
+
+// WithHTTPClient sets the underlying HTTP client.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithTimeout bounds each request made to the API. A zero or negative value
+// disables the per-request timeout, leaving cancellation to the caller's
+// context.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.timeout = timeout
+	}
+}
+
+// WithRetryPolicy sets how failed requests are retried. Defaults to
+// DefaultRetryPolicy.
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(c *Client) {
+		c.retry = policy
+	}
+}
+
+// WithLimiter sets the rate limiter applied to every request, including
+// retries. Pass the same Limiter to clients sharing an API key. A nil
+// Limiter disables client-side rate limiting.
+func WithLimiter(limiter *Limiter) Option {
+	return func(c *Client) {
+		c.limiter = limiter
+	}
+}
+
+// NewClient returns a Client configured with the given options.
+func NewClient(opts ...Option) *Client {
+	c := &Client{
+		baseURL:    defaultBaseURL,
+		apiKey:     os.Getenv("FRED_API_KEY"),
+		httpClient: &http.Client{},
+		timeout:    defaultTimeout,
+		retry:      DefaultRetryPolicy,
+		limiter:    NewLimiter(defaultRateLimit, time.Minute, defaultBurst),
+		mode:       ModeLive,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// get performs a GET request against the given API path and decodes the JSON
+// response into v. The API key and file type are added to query. Requests
+// are rate limited and retried according to the client's retry policy. In
+// replay mode the response comes from the snapshot store instead, and in
+// record mode it is written to it.
+func (c *Client) get(ctx context.Context, path string, query url.Values, v any) error {
+	body, err := c.fetch(ctx, path, query)
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("failed to parse FRED response: %w", err)
+	}
+	return nil
+}
+
+// fetch returns the response body for a request, honoring the client mode
+func (c *Client) fetch(ctx context.Context, path string, query url.Values) ([]byte, error) {
+	if c.mode == ModeReplay {
+		return c.snapshots.Load(path, query)
+	}
+
+	body, err := c.fetchLive(ctx, path, query)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.mode == ModeRecord {
+		if err := c.snapshots.Save(path, query, body); err != nil {
+			return nil, fmt.Errorf("failed to record FRED response: %w", err)
+		}
+	}
+
+	return body, nil
+}
+
+// fetchLive requests path from the FRED API, retrying as needed
+func (c *Client) fetchLive(ctx context.Context, path string, query url.Values) ([]byte, error) {
+	if c.apiKey == "" {
+		return nil, fmt.Errorf("%w: FRED API key not set", ErrBadAPIKey)
+	}
+
+	// Copy so the caller's query (used for snapshot keys) never holds the key
+	params := url.Values{}
+	for k, v := range query {
+		params[k] = v
+	}
+	params.Set("api_key", c.apiKey)
+	params.Set("file_type", "json")
+	requestURL := c.baseURL + path + "?" + params.Encode()
+	seriesID := query.Get("series_id")
+
+	for attempt := 1; ; attempt++ {
+		body, retryAfter, retry, err := c.do(ctx, requestURL, seriesID)
+		if err == nil {
+			return body, nil
+		}
+
+		if !retry || attempt >= c.retry.MaxAttempts {
+			return nil, err
+		}
+
+		// Give up with the last API error if the caller goes away mid-backoff
+		if sleep(ctx, c.retry.delay(attempt, retryAfter)) != nil {
+			return nil, err
+		}
+	}
+}
+
+// do makes a single request attempt. It returns the response body on
+// success, or an error (an *APIError for non-200 responses) along with
+// whether the attempt may be retried and any Retry-After delay requested by
+// the server.
+func (c *Client) do(ctx context.Context, requestURL, seriesID string) ([]byte, time.Duration, bool, error) {
+	if c.limiter != nil {
+		if err := c.limiter.Wait(ctx); err != nil {
+			return nil, 0, false, err
+		}
+	}
+
+	reqCtx := ctx
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		reqCtx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	req, err := http.NewRequestWithContext(reqCtx, http.MethodGet, requestURL, nil)
+	if err != nil {
+		return nil, 0, false, fmt.Errorf("failed to build FRED request: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		// Per-attempt timeouts are retried; the caller giving up is not
+		retry := ctx.Err() == nil
+		// Provide better error messages for common network issues
+		if _, ok := err.(net.Error); ok {
+			return nil, 0, retry, fmt.Errorf("network error: %w", err)
+		}
+		return nil, 0, retry, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, parseRetryAfter(resp.Header.Get("Retry-After")), retryableStatus(resp.StatusCode),
+			parseAPIError(resp.StatusCode, body, seriesID)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, true, fmt.Errorf("failed to read FRED response: %w", err)
+	}
+
+	return body, 0, false, nil
+}
@@ -0,0 +1,156 @@
+package fred_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/shanehull/shanehull.com/internal/fred"
+	"github.com/shanehull/shanehull.com/internal/fred/fredtest"
+)
+
+func TestRetry(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		failures     []int
+		wantRequests int
+		wantStatus   int // 0 if the fetch succeeds
+	}{
+		{name: "rate limited", failures: []int{429}, wantRequests: 2},
+		{name: "server errors", failures: []int{500, 503}, wantRequests: 3},
+		{name: "gives up after max attempts", failures: []int{502, 502, 502}, wantRequests: 3, wantStatus: 502},
+		{name: "client error is not retried", failures: []int{400}, wantRequests: 1, wantStatus: 400},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := fredtest.NewServer()
+			defer srv.Close()
+			srv.AddSeries("GDP", fred.FrequencyQuarterly, fredtest.Points(fred.FrequencyQuarterly, start, 1, 2, 3))
+			srv.FailNext(tt.failures...)
+
+			// The fredtest client makes at most 3 attempts
+			points, err := srv.Client().FetchSeries(context.Background(), "GDP", nil)
+
+			if got := len(srv.Requests()); got != tt.wantRequests {
+				t.Errorf("made %d requests, want %d", got, tt.wantRequests)
+			}
+
+			if tt.wantStatus == 0 {
+				if err != nil {
+					t.Fatalf("FetchSeries() error = %v", err)
+				}
+				if len(points) != 3 {
+					t.Errorf("FetchSeries() returned %d points, want 3", len(points))
+				}
+				return
+			}
+
+			var apiErr *fred.APIError
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("FetchSeries() error = %v, want an *APIError", err)
+			}
+			if apiErr.StatusCode != tt.wantStatus {
+				t.Errorf("StatusCode = %d, want %d", apiErr.StatusCode, tt.wantStatus)
+			}
+			if got, want := apiErr.Temporary(), tt.wantStatus >= 500; got != want {
+				t.Errorf("Temporary() = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+// observationsBody is a successful observations response with one point
+const observationsBody = `{"count":1,"offset":0,"limit":100000,"observations":[{"date":"2020-01-01","value":"1"}]}`
+
+// flakyServer fails the first failures requests with status and the given
+// Retry-After header, then succeeds. It returns the server and a count of the
+// requests it has received.
+func flakyServer(t *testing.T, failures int, status int, retryAfter string) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if int(requests.Add(1)) <= failures {
+			if retryAfter != "" {
+				w.Header().Set("Retry-After", retryAfter)
+			}
+			w.WriteHeader(status)
+			fmt.Fprintf(w, `{"error_code":%d,"error_message":"Try again later."}`, status)
+			return
+		}
+		_, _ = w.Write([]byte(observationsBody))
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv, &requests
+}
+
+func TestRetryAfterCapped(t *testing.T) {
+	srv, requests := flakyServer(t, 1, http.StatusTooManyRequests, "3600")
+
+	client := fred.NewClient(
+		fred.WithBaseURL(srv.URL),
+		fred.WithAPIKey("key"),
+		fred.WithLimiter(nil),
+		fred.WithRetryPolicy(fred.RetryPolicy{
+			MaxAttempts: 2,
+			BaseDelay:   time.Millisecond,
+			MaxDelay:    10 * time.Millisecond,
+		}),
+	)
+
+	// An hour's Retry-After is capped at MaxDelay rather than waited out
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	points, err := client.FetchSeries(ctx, "GDP", nil)
+	if err != nil {
+		t.Fatalf("FetchSeries() error = %v", err)
+	}
+	if len(points) != 1 {
+		t.Errorf("FetchSeries() returned %d points, want 1", len(points))
+	}
+	if got := requests.Load(); got != 2 {
+		t.Errorf("made %d requests, want 2", got)
+	}
+}
+
+func TestRetryCancelledDuringBackoff(t *testing.T) {
+	srv, requests := flakyServer(t, 1, http.StatusServiceUnavailable, "")
+
+	client := fred.NewClient(
+		fred.WithBaseURL(srv.URL),
+		fred.WithAPIKey("key"),
+		fred.WithLimiter(nil),
+		fred.WithRetryPolicy(fred.RetryPolicy{
+			MaxAttempts: 2,
+			BaseDelay:   time.Hour,
+			MaxDelay:    time.Hour,
+		}),
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	began := time.Now()
+	_, err := client.FetchSeries(ctx, "GDP", nil)
+	if elapsed := time.Since(began); elapsed > 5*time.Second {
+		t.Errorf("FetchSeries() returned after %v, want it to stop when the context is done", elapsed)
+	}
+
+	// The last API error is returned rather than the context's
+	var apiErr *fred.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("FetchSeries() error = %v, want the 503 *APIError", err)
+	}
+	if got := requests.Load(); got != 1 {
+		t.Errorf("made %d requests, want 1", got)
+	}
+}
@@ -0,0 +1,100 @@
+package fred
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+var (
+	// ErrBadAPIKey is returned when the API key is missing or rejected
+	ErrBadAPIKey = errors.New("fred: invalid API key")
+
+	// ErrSeriesNotFound is returned when a series does not exist, e.g.
+	// because it has been discontinued
+	ErrSeriesNotFound = errors.New("fred: series not found")
+
+	// ErrRateLimited is returned when FRED throttles the API key
+	ErrRateLimited = errors.New("fred: rate limited")
+
+	// ErrInvalidOptions is returned when FetchOptions are rejected before
+	// a request is sent
+	ErrInvalidOptions = errors.New("fred: invalid options")
+
+	// ErrNoScheduledRelease is returned when a series is not part of a
+	// release on FRED's release calendar
+	ErrNoScheduledRelease = errors.New("fred: no scheduled release")
+)
+
+// APIError is a non-200 response from the FRED API. It matches the sentinel
+// errors above with errors.Is.
+type APIError struct {
+	// StatusCode is the HTTP status of the response
+	StatusCode int
+
+	// Code and Message are FRED's error_code and error_message, if the body
+	// could be parsed
+	Code    int
+	Message string
+
+	// SeriesID is the series the request was for, if any
+	SeriesID string
+}
+
+func (e *APIError) Error() string {
+	msg := e.Message
+	if msg == "" {
+		msg = http.StatusText(e.StatusCode)
+	}
+	if e.SeriesID != "" {
+		return fmt.Sprintf("FRED API error for series %s (status %d): %s", e.SeriesID, e.StatusCode, msg)
+	}
+	return fmt.Sprintf("FRED API error (status %d): %s", e.StatusCode, msg)
+}
+
+// Is reports whether the error matches one of the package sentinel errors.
+// FRED signals most client errors with a 400 and a message, so the message
+// is used to tell them apart.
+func (e *APIError) Is(target error) bool {
+	msg := strings.ToLower(e.Message)
+	switch target {
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests
+	case ErrBadAPIKey:
+		return e.StatusCode == http.StatusUnauthorized ||
+			e.StatusCode == http.StatusForbidden ||
+			strings.Contains(msg, "api_key")
+	case ErrSeriesNotFound:
+		return e.StatusCode == http.StatusNotFound ||
+			strings.Contains(msg, "series does not exist")
+	}
+	return false
+}
+
+// Temporary reports whether the request may succeed if retried later
+func (e *APIError) Temporary() bool {
+	return retryableStatus(e.StatusCode)
+}
+
+// parseAPIError builds an APIError from a non-200 response body
+func parseAPIError(statusCode int, body []byte, seriesID string) *APIError {
+	apiErr := &APIError{
+		StatusCode: statusCode,
+		SeriesID:   seriesID,
+	}
+
+	var fredErr struct {
+		Code    int    `json:"error_code"`
+		Message string `json:"error_message"`
+	}
+	if err := json.Unmarshal(body, &fredErr); err == nil && fredErr.Message != "" {
+		apiErr.Code = fredErr.Code
+		apiErr.Message = fredErr.Message
+	} else {
+		apiErr.Message = strings.TrimSpace(string(body))
+	}
+
+	return apiErr
+}
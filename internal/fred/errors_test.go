@@ -0,0 +1,103 @@
+package fred
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestParseAPIError(t *testing.T) {
+	sentinels := []error{ErrBadAPIKey, ErrSeriesNotFound, ErrRateLimited}
+
+	tests := []struct {
+		name        string
+		status      int
+		body        string
+		wantCode    int
+		wantMessage string
+		want        error // the sentinel matched, if any
+	}{
+		{
+			name:        "bad API key",
+			status:      400,
+			body:        `{"error_code":400,"error_message":"Bad Request.  The value for variable api_key is not registered.  Read https:\/\/fred.stlouisfed.org\/docs\/api\/api_key.html for more information."}`,
+			wantCode:    400,
+			wantMessage: "Bad Request.  The value for variable api_key is not registered.  Read https://fred.stlouisfed.org/docs/api/api_key.html for more information.",
+			want:        ErrBadAPIKey,
+		},
+		{
+			name:        "missing API key",
+			status:      400,
+			body:        `{"error_code":400,"error_message":"Bad Request.  Variable api_key is not set.  Read https:\/\/fred.stlouisfed.org\/docs\/api\/api_key.html for more information."}`,
+			wantCode:    400,
+			wantMessage: "Bad Request.  Variable api_key is not set.  Read https://fred.stlouisfed.org/docs/api/api_key.html for more information.",
+			want:        ErrBadAPIKey,
+		},
+		{
+			name:        "series not found",
+			status:      400,
+			body:        `{"error_code":400,"error_message":"Bad Request.  The series does not exist."}`,
+			wantCode:    400,
+			wantMessage: "Bad Request.  The series does not exist.",
+			want:        ErrSeriesNotFound,
+		},
+		{
+			name:        "not found status",
+			status:      404,
+			body:        `{"error_code":404,"error_message":"Not Found"}`,
+			wantCode:    404,
+			wantMessage: "Not Found",
+			want:        ErrSeriesNotFound,
+		},
+		{
+			name:        "rate limited",
+			status:      429,
+			body:        `{"error_code":429,"error_message":"Too Many Requests.  Exceeded Rate Limit"}`,
+			wantCode:    429,
+			wantMessage: "Too Many Requests.  Exceeded Rate Limit",
+			want:        ErrRateLimited,
+		},
+		{
+			name:        "other bad request",
+			status:      400,
+			body:        `{"error_code":400,"error_message":"Bad Request.  Variable frequency is not one of the allowed values."}`,
+			wantCode:    400,
+			wantMessage: "Bad Request.  Variable frequency is not one of the allowed values.",
+		},
+		{
+			name:        "non-JSON body",
+			status:      502,
+			body:        "<html><body>Bad Gateway</body></html>\n",
+			wantMessage: "<html><body>Bad Gateway</body></html>",
+		},
+		{
+			name:        "rate limited without a JSON body",
+			status:      429,
+			body:        "Too Many Requests",
+			wantMessage: "Too Many Requests",
+			want:        ErrRateLimited,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Errors reach callers wrapped, so match through a wrapper
+			err := fmt.Errorf("failed to fetch FRED series GDP: %w", parseAPIError(tt.status, []byte(tt.body), "GDP"))
+
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("errors.As(%v, *APIError) = false", err)
+			}
+			if apiErr.StatusCode != tt.status || apiErr.Code != tt.wantCode || apiErr.Message != tt.wantMessage || apiErr.SeriesID != "GDP" {
+				t.Errorf("parseAPIError() = %+v, want status %d, code %d, message %q and series GDP",
+					*apiErr, tt.status, tt.wantCode, tt.wantMessage)
+			}
+
+			for _, sentinel := range sentinels {
+				if got, want := errors.Is(err, sentinel), sentinel == tt.want; got != want {
+					t.Errorf("errors.Is(err, %v) = %v, want %v", sentinel, got, want)
+				}
+			}
+		})
+	}
+}
@@ -1,27 +1,38 @@
+// Package fred implements a client for the FRED economic data API
 package fred
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"io"
-	"net"
-	"net/http"
+	"iter"
+	"math"
 	"net/url"
-	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
-const baseURL = "https://api.stlouisfed.org/fred/series/observations"
+const (
+	observationsPath = "/series/observations"
+
+	// maxLimit is the most observations FRED returns in one request
+	maxLimit = 100000
+)
 
 // Observation represents a single data point from FRED
 type Observation struct {
-	Date  string `json:"date"`
-	Value string `json:"value"`
+	RealtimeStart string `json:"realtime_start"`
+	RealtimeEnd   string `json:"realtime_end"`
+	Date          string `json:"date"`
+	Value         string `json:"value"`
 }
 
-// Response represents the FRED API response
+// Response represents the FRED API response. Count is the total number of
+// observations available; Offset and Limit describe the returned page.
 type Response struct {
+	Count        int           `json:"count"`
+	Offset       int           `json:"offset"`
+	Limit        int           `json:"limit"`
 	Observations []Observation `json:"observations"`
 }
 
@@ -29,6 +40,16 @@ type Response struct {
 type DataPoint struct {
 	Date  time.Time
 	Value float64
+
+	// Missing is set for observations FRED has no value for. Their Value is
+	// NaN. Missing points are only returned if FetchOptions.KeepMissing is
+	// set.
+	Missing bool
+
+	// RealtimeStart is the start of the real-time period the value was
+	// current for. It is only set when FetchOptions requests a real-time
+	// period or vintage dates.
+	RealtimeStart time.Time
 }
 
 // FetchOptions configures parameters for fetching FRED series data.
@@ -42,18 +63,21 @@ type FetchOptions struct {
 	// Defaults to today if not set.
 	ObservationEnd *time.Time
 
-	// Frequency aggregates data: "d" (daily), "w" (weekly), "bw" (biweekly),
-	// "m" (monthly), "q" (quarterly), "sa" (semi-annual), "a" (annual).
-	// Defaults to "q" (quarterly) if not set.
-	Frequency string
+	// Frequency aggregates data to a lower frequency, e.g. FrequencyMonthly.
+	// Defaults to FrequencyQuarterly if not set.
+	Frequency Frequency
+
+	// AggregationMethod sets how observations are combined when Frequency is
+	// lower than the series' native frequency. FRED defaults to
+	// AggregationAverage if not set. Requires Frequency to be set explicitly.
+	AggregationMethod AggregationMethod
 
-	// Units specifies the transformation: "lin" (linear), "chg" (change),
-	// "ch1" (change from 1 year ago), "pch" (percent change),
-	// "pc1" (percent change from 1 year ago), "pca" (percent change annual rate).
-	// Defaults to "lin" (linear) if not set.
-	Units string
+	// Units specifies the transformation applied to the data, e.g.
+	// UnitsPercentChangeFromYearAgo. Defaults to UnitsLevels if not set.
+	Units Units
 
-	// Limit the maximum number of observations returned (1-100000).
+	// Limit the maximum number of observations returned. FRED caps a single
+	// request at 100000; larger series are paged through automatically.
 	// If not set or 0, no limit is applied.
 	Limit int
 
@@ -64,6 +88,108 @@ type FetchOptions struct {
 	// SortOrder determines result order: "asc" (ascending) or "desc" (descending).
 	// Defaults to "asc" if not set.
 	SortOrder string
+
+	// RealtimeStart and RealtimeEnd select the ALFRED real-time period, i.e.
+	// the data as it was known between those dates rather than as revised
+	// since. Setting both to the same date gives the series as it looked on
+	// that day. If nil, FRED uses today.
+	RealtimeStart *time.Time
+	RealtimeEnd   *time.Time
+
+	// VintageDates requests the series as it existed on each of these dates.
+	// It cannot be combined with RealtimeStart or RealtimeEnd.
+	VintageDates []time.Time
+
+	// KeepMissing keeps observations without a value as Missing points
+	// rather than dropping them, so gaps in a series stay visible.
+	KeepMissing bool
+}
+
+// Series is a fetched series along with a report of how its rows were
+// handled
+type Series struct {
+	ID     string
+	Points []DataPoint
+	Report FetchReport
+}
+
+// FetchReport summarises the rows FRED returned for a series and what was
+// done with them
+type FetchReport struct {
+	SeriesID string `json:"series_id"`
+
+	// Rows is the number of rows FRED returned
+	Rows int `json:"rows"`
+
+	// Missing is the number of rows with no value ("."). They are dropped
+	// unless KeptMissing is set.
+	Missing     int  `json:"missing"`
+	KeptMissing bool `json:"kept_missing"`
+
+	// InvalidDate and InvalidValue count rows that could not be parsed.
+	// Rows with an invalid date are always dropped; rows with an invalid
+	// value are treated as missing.
+	InvalidDate  int `json:"invalid_date"`
+	InvalidValue int `json:"invalid_value"`
+}
+
+// Skipped returns the number of rows that were dropped
+func (r FetchReport) Skipped() int {
+	skipped := r.InvalidDate
+	if !r.KeptMissing {
+		skipped += r.Missing + r.InvalidValue
+	}
+	return skipped
+}
+
+func (r FetchReport) String() string {
+	return fmt.Sprintf("series %s: %d rows, %d missing, %d invalid date, %d invalid value, %d skipped",
+		r.SeriesID, r.Rows, r.Missing, r.InvalidDate, r.InvalidValue, r.Skipped())
+}
+
+// realtime reports whether the options request a point-in-time view
+func (opts *FetchOptions) realtime() bool {
+	return opts.RealtimeStart != nil || opts.RealtimeEnd != nil || len(opts.VintageDates) > 0
+}
+
+// Validate checks for values and combinations FRED would reject, so bad
+// options fail with a clear error before a request is sent. Fetches call it
+// on the options as given, before defaults are applied, and wrap its error
+// in ErrInvalidOptions.
+func (opts *FetchOptions) Validate() error {
+	if opts.Frequency != "" && !opts.Frequency.Valid() {
+		return fmt.Errorf("unknown frequency %q", opts.Frequency)
+	}
+	if opts.Units != "" && !opts.Units.Valid() {
+		return fmt.Errorf("unknown units %q", opts.Units)
+	}
+	if opts.AggregationMethod != "" {
+		if !opts.AggregationMethod.Valid() {
+			return fmt.Errorf("unknown aggregation method %q", opts.AggregationMethod)
+		}
+		if opts.Frequency == "" {
+			return fmt.Errorf("aggregation method %q requires a frequency", opts.AggregationMethod)
+		}
+		if opts.Frequency == FrequencyDaily {
+			return fmt.Errorf("aggregation method %q has no effect at daily frequency", opts.AggregationMethod)
+		}
+	}
+	if opts.SortOrder != "" && opts.SortOrder != "asc" && opts.SortOrder != "desc" {
+		return fmt.Errorf("unknown sort order %q", opts.SortOrder)
+	}
+	if opts.Limit < 0 {
+		return fmt.Errorf("negative limit %d", opts.Limit)
+	}
+	if opts.Offset < 0 {
+		return fmt.Errorf("negative offset %d", opts.Offset)
+	}
+	if len(opts.VintageDates) > 0 && (opts.RealtimeStart != nil || opts.RealtimeEnd != nil) {
+		return fmt.Errorf("vintage dates cannot be combined with a real-time period")
+	}
+	if opts.RealtimeStart != nil && opts.RealtimeEnd != nil && opts.RealtimeEnd.Before(*opts.RealtimeStart) {
+		return fmt.Errorf("real-time period ends before it starts")
+	}
+	return nil
 }
 
 // applyDefaults fills in missing FetchOptions with sensible defaults
@@ -73,104 +199,188 @@ func (opts *FetchOptions) applyDefaults() {
 		opts.ObservationEnd = &end
 	}
 	if opts.Frequency == "" {
-		opts.Frequency = "q"
+		opts.Frequency = FrequencyQuarterly
 	}
 	if opts.Units == "" {
-		opts.Units = "lin"
+		opts.Units = UnitsLevels
 	}
 	if opts.SortOrder == "" {
 		opts.SortOrder = "asc"
 	}
 }
 
-// FetchSeries retrieves observations for a given FRED series ID.
-// The opts parameter can be nil to use sensible defaults.
-func FetchSeries(seriesID string, opts *FetchOptions) ([]DataPoint, error) {
-	apiKey := os.Getenv("FRED_API_KEY")
-	if apiKey == "" {
-		return nil, fmt.Errorf("FRED_API_KEY environment variable not set")
+// FetchSeries retrieves observations for a given FRED series ID, paging
+// through results until every observation in the requested range has been
+// fetched. The opts parameter can be nil to use sensible defaults.
+func (c *Client) FetchSeries(ctx context.Context, seriesID string, opts *FetchOptions) ([]DataPoint, error) {
+	series, err := c.FetchSeriesWithReport(ctx, seriesID, opts)
+	if err != nil {
+		return nil, err
 	}
+	return series.Points, nil
+}
 
-	if opts == nil {
-		opts = &FetchOptions{}
+// FetchSeriesWithReport is like FetchSeries but also reports how many rows
+// were missing or could not be parsed.
+func (c *Client) FetchSeriesWithReport(ctx context.Context, seriesID string, opts *FetchOptions) (*Series, error) {
+	series := &Series{
+		ID:     seriesID,
+		Points: make([]DataPoint, 0),
 	}
-	opts.applyDefaults()
-
-	// Build query parameters
-	query := url.Values{}
-	query.Set("series_id", seriesID)
-	query.Set("api_key", apiKey)
-	query.Set("file_type", "json")
-	if opts.ObservationStart != nil {
-		query.Set("observation_start", opts.ObservationStart.Format("2006-01-02"))
+	for point, err := range c.observations(ctx, seriesID, opts, &series.Report) {
+		if err != nil {
+			return nil, err
+		}
+		series.Points = append(series.Points, point)
 	}
-	query.Set("observation_end", opts.ObservationEnd.Format("2006-01-02"))
-	query.Set("frequency", opts.Frequency)
-	query.Set("units", opts.Units)
-	query.Set("sort_order", opts.SortOrder)
 
-	if opts.Limit > 0 {
-		query.Set("limit", strconv.Itoa(opts.Limit))
-	}
-	if opts.Offset > 0 {
-		query.Set("offset", strconv.Itoa(opts.Offset))
+	if len(series.Points) == 0 {
+		return nil, fmt.Errorf("no data available for series %s in the requested time range", seriesID)
 	}
 
-	requestURL := baseURL + "?" + query.Encode()
+	return series, nil
+}
 
-	resp, err := http.Get(requestURL)
-	if err != nil {
-		// Provide better error messages for common network issues
-		if _, ok := err.(net.Error); ok {
-			return nil, fmt.Errorf("network error fetching FRED series %s: %w", seriesID, err)
+// Observations returns an iterator over the observations of a series. Pages
+// of up to 100000 observations are requested lazily as iteration proceeds,
+// so stopping early avoids fetching the rest. opts.Limit caps the total
+// number of observations across pages. If a request fails, the error is
+// yielded once and iteration stops.
+func (c *Client) Observations(ctx context.Context, seriesID string, opts *FetchOptions) iter.Seq2[DataPoint, error] {
+	return c.observations(ctx, seriesID, opts, &FetchReport{})
+}
+
+// observations implements Observations, recording row counts in report
+func (c *Client) observations(ctx context.Context, seriesID string, opts *FetchOptions, report *FetchReport) iter.Seq2[DataPoint, error] {
+	return func(yield func(DataPoint, error) bool) {
+		o, query, err := observationsQuery(seriesID, opts)
+		if err != nil {
+			yield(DataPoint{}, err)
+			return
 		}
-		return nil, fmt.Errorf("failed to fetch FRED series %s: %w", seriesID, err)
-	}
-	defer func() {
-		_ = resp.Body.Close()
-	}()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("FRED API error (status %d): %s", resp.StatusCode, string(body))
+		*report = FetchReport{
+			SeriesID:    seriesID,
+			KeptMissing: o.KeepMissing,
+		}
+
+		offset := o.Offset
+		remaining := o.Limit
+		for {
+			pageLimit := maxLimit
+			if o.Limit > 0 && remaining < pageLimit {
+				pageLimit = remaining
+			}
+			query.Set("limit", strconv.Itoa(pageLimit))
+			if offset > 0 {
+				query.Set("offset", strconv.Itoa(offset))
+			}
+
+			var page Response
+			if err := c.get(ctx, observationsPath, query, &page); err != nil {
+				yield(DataPoint{}, fmt.Errorf("failed to fetch FRED series %s: %w", seriesID, err))
+				return
+			}
+
+			for _, obs := range page.Observations {
+				point, ok := parseObservation(obs, &o, report)
+				if !ok {
+					continue
+				}
+				if !yield(point, nil) {
+					return
+				}
+			}
+
+			offset += len(page.Observations)
+			remaining -= len(page.Observations)
+			if len(page.Observations) == 0 || offset >= page.Count || (o.Limit > 0 && remaining <= 0) {
+				return
+			}
+		}
 	}
+}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read FRED response: %w", err)
+// observationsQuery validates opts and builds the query parameters for a
+// series/observations request, excluding limit and offset
+func observationsQuery(seriesID string, opts *FetchOptions) (FetchOptions, url.Values, error) {
+	// Work on a copy so callers can share options between fetches
+	o := FetchOptions{}
+	if opts != nil {
+		o = *opts
+	}
+	if err := o.Validate(); err != nil {
+		return o, nil, fmt.Errorf("%w for FRED series %s: %w", ErrInvalidOptions, seriesID, err)
 	}
+	o.applyDefaults()
 
-	var fredResp Response
-	if err := json.Unmarshal(body, &fredResp); err != nil {
-		return nil, fmt.Errorf("failed to parse FRED response: %w", err)
+	// Build query parameters
+	query := url.Values{}
+	query.Set("series_id", seriesID)
+	if o.ObservationStart != nil {
+		query.Set("observation_start", o.ObservationStart.Format("2006-01-02"))
 	}
+	query.Set("observation_end", o.ObservationEnd.Format("2006-01-02"))
+	query.Set("frequency", string(o.Frequency))
+	query.Set("units", string(o.Units))
+	if o.AggregationMethod != "" {
+		query.Set("aggregation_method", string(o.AggregationMethod))
+	}
+	query.Set("sort_order", o.SortOrder)
 
-	data := make([]DataPoint, 0, len(fredResp.Observations))
-	for _, obs := range fredResp.Observations {
-		// Skip missing values represented as "."
-		if obs.Value == "." {
-			continue
+	if o.RealtimeStart != nil {
+		query.Set("realtime_start", o.RealtimeStart.Format("2006-01-02"))
+	}
+	if o.RealtimeEnd != nil {
+		query.Set("realtime_end", o.RealtimeEnd.Format("2006-01-02"))
+	}
+	if len(o.VintageDates) > 0 {
+		dates := make([]string, len(o.VintageDates))
+		for i, d := range o.VintageDates {
+			dates[i] = d.Format("2006-01-02")
 		}
+		query.Set("vintage_dates", strings.Join(dates, ","))
+	}
 
-		parsedDate, err := time.Parse("2006-01-02", obs.Date)
-		if err != nil {
-			continue
-		}
+	return o, query, nil
+}
 
-		value, err := strconv.ParseFloat(obs.Value, 64)
-		if err != nil {
-			continue
-		}
+// parseObservation converts an observation into a DataPoint, counting it in
+// report. It returns false if the row should be dropped: rows with an
+// invalid date always are, and rows without a valid value are unless
+// KeepMissing is set.
+func parseObservation(obs Observation, opts *FetchOptions, report *FetchReport) (DataPoint, bool) {
+	report.Rows++
 
-		data = append(data, DataPoint{
-			Date:  parsedDate,
-			Value: value,
-		})
+	parsedDate, err := time.Parse("2006-01-02", obs.Date)
+	if err != nil {
+		report.InvalidDate++
+		return DataPoint{}, false
 	}
 
-	if len(data) == 0 {
-		return nil, fmt.Errorf("no data available for series %s in the requested time range", seriesID)
+	point := DataPoint{
+		Date: parsedDate,
+	}
+	if opts.realtime() {
+		point.RealtimeStart, _ = time.Parse("2006-01-02", obs.RealtimeStart)
+	}
+
+	// Missing values are represented as "."
+	if obs.Value == "." {
+		report.Missing++
+		point.Value = math.NaN()
+		point.Missing = true
+		return point, opts.KeepMissing
+	}
+
+	value, err := strconv.ParseFloat(obs.Value, 64)
+	if err != nil {
+		report.InvalidValue++
+		point.Value = math.NaN()
+		point.Missing = true
+		return point, opts.KeepMissing
 	}
+	point.Value = value
 
-	return data, nil
+	return point, true
 }
@@ -0,0 +1,335 @@
+package fred_test
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/shanehull/shanehull.com/internal/fred"
+	"github.com/shanehull/shanehull.com/internal/fred/fredtest"
+)
+
+// pageSize is the most observations FRED returns in one request
+const pageSize = 100000
+
+// observationPages returns the limit and offset params of each observations
+// request the server received
+func observationPages(srv *fredtest.Server) [][2]string {
+	var pages [][2]string
+	for _, r := range srv.Requests() {
+		if r.URL.Path != "/series/observations" {
+			continue
+		}
+		q := r.URL.Query()
+		pages = append(pages, [2]string{q.Get("limit"), q.Get("offset")})
+	}
+	return pages
+}
+
+func TestFetchSeriesPaging(t *testing.T) {
+	// Daily points are weekdays, so they run past today and before FRED's
+	// default observation start
+	start := time.Date(1700, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC)
+	values := make([]float64, pageSize+10)
+	for i := range values {
+		values[i] = float64(i)
+	}
+
+	tests := []struct {
+		name      string
+		opts      fred.FetchOptions
+		wantFirst float64
+		wantLen   int
+		wantPages [][2]string
+	}{
+		{
+			name:      "past the page cap",
+			opts:      fred.FetchOptions{},
+			wantFirst: 0,
+			wantLen:   pageSize + 10,
+			wantPages: [][2]string{{"100000", ""}, {"100000", "100000"}},
+		},
+		{
+			name:      "limit spanning pages",
+			opts:      fred.FetchOptions{Limit: pageSize + 5},
+			wantFirst: 0,
+			wantLen:   pageSize + 5,
+			wantPages: [][2]string{{"100000", ""}, {"5", "100000"}},
+		},
+		{
+			name:      "offset",
+			opts:      fred.FetchOptions{Offset: 7},
+			wantFirst: 7,
+			wantLen:   pageSize + 3,
+			wantPages: [][2]string{{"100000", "7"}, {"100000", "100007"}},
+		},
+		{
+			name:      "offset and limit",
+			opts:      fred.FetchOptions{Offset: 7, Limit: 3},
+			wantFirst: 7,
+			wantLen:   3,
+			wantPages: [][2]string{{"3", "7"}},
+		},
+		{
+			name:      "descending",
+			opts:      fred.FetchOptions{SortOrder: "desc", Limit: 4},
+			wantFirst: pageSize + 9,
+			wantLen:   4,
+			wantPages: [][2]string{{"4", ""}},
+		},
+		{
+			name:      "descending past the page cap",
+			opts:      fred.FetchOptions{SortOrder: "desc"},
+			wantFirst: pageSize + 9,
+			wantLen:   pageSize + 10,
+			wantPages: [][2]string{{"100000", ""}, {"100000", "100000"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := fredtest.NewServer()
+			defer srv.Close()
+			srv.AddSeries("DAILY", fred.FrequencyDaily, fredtest.Points(fred.FrequencyDaily, start, values...))
+
+			opts := tt.opts
+			opts.Frequency = fred.FrequencyDaily
+			opts.ObservationStart = &start
+			opts.ObservationEnd = &end
+			points, err := srv.Client().FetchSeries(context.Background(), "DAILY", &opts)
+			if err != nil {
+				t.Fatalf("FetchSeries() error = %v", err)
+			}
+
+			if len(points) != tt.wantLen {
+				t.Fatalf("FetchSeries() returned %d points, want %d", len(points), tt.wantLen)
+			}
+			if points[0].Value != tt.wantFirst {
+				t.Errorf("first point = %v, want %v", points[0].Value, tt.wantFirst)
+			}
+
+			// Points are consecutive in the requested order across pages
+			step := 1.0
+			if opts.SortOrder == "desc" {
+				step = -1
+			}
+			for i := 1; i < len(points); i++ {
+				if points[i].Value != points[i-1].Value+step {
+					t.Fatalf("point %d = %v after %v, want consecutive values", i, points[i].Value, points[i-1].Value)
+				}
+			}
+
+			if got := observationPages(srv); !slices.Equal(got, tt.wantPages) {
+				t.Errorf("requested pages (limit, offset) = %v, want %v", got, tt.wantPages)
+			}
+		})
+	}
+}
+
+func TestObservationsStopEarly(t *testing.T) {
+	srv := fredtest.NewServer()
+	defer srv.Close()
+
+	start := time.Date(1700, 1, 1, 0, 0, 0, 0, time.UTC)
+	values := make([]float64, pageSize+10)
+	srv.AddSeries("DAILY", fred.FrequencyDaily, fredtest.Points(fred.FrequencyDaily, start, values...))
+
+	// Stopping within the first page never requests the second
+	n := 0
+	for _, err := range srv.Client().Observations(context.Background(), "DAILY", &fred.FetchOptions{Frequency: fred.FrequencyDaily}) {
+		if err != nil {
+			t.Fatalf("Observations() error = %v", err)
+		}
+		if n++; n == 10 {
+			break
+		}
+	}
+
+	if got := len(observationPages(srv)); got != 1 {
+		t.Errorf("made %d observations requests, want 1", got)
+	}
+}
+
+func TestFetchSeriesInvalidOptions(t *testing.T) {
+	srv := fredtest.NewServer()
+	defer srv.Close()
+
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	srv.AddSeries("GDP", fred.FrequencyQuarterly, fredtest.Points(fred.FrequencyQuarterly, start, 1, 2, 3))
+
+	end := start.AddDate(1, 0, 0)
+	tests := []struct {
+		name string
+		opts fred.FetchOptions
+	}{
+		{name: "unknown frequency", opts: fred.FetchOptions{Frequency: "fortnightly"}},
+		{name: "unknown units", opts: fred.FetchOptions{Units: "percent"}},
+		{name: "unknown aggregation method", opts: fred.FetchOptions{Frequency: fred.FrequencyAnnual, AggregationMethod: "median"}},
+		{name: "aggregation without frequency", opts: fred.FetchOptions{AggregationMethod: fred.AggregationSum}},
+		{name: "aggregation at daily frequency", opts: fred.FetchOptions{Frequency: fred.FrequencyDaily, AggregationMethod: fred.AggregationSum}},
+		{name: "unknown sort order", opts: fred.FetchOptions{SortOrder: "newest"}},
+		{name: "negative limit", opts: fred.FetchOptions{Limit: -1}},
+		{name: "negative offset", opts: fred.FetchOptions{Offset: -1}},
+		{name: "vintage dates with a real-time period", opts: fred.FetchOptions{VintageDates: []time.Time{start}, RealtimeStart: &start}},
+		{name: "real-time period ending before it starts", opts: fred.FetchOptions{RealtimeStart: &end, RealtimeEnd: &start}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.opts.Validate(); err == nil {
+				t.Error("Validate() = nil, want an error")
+			}
+
+			before := len(srv.Requests())
+			_, err := srv.Client().FetchSeries(context.Background(), "GDP", &tt.opts)
+			if !errors.Is(err, fred.ErrInvalidOptions) {
+				t.Errorf("FetchSeries() error = %v, want %v", err, fred.ErrInvalidOptions)
+			}
+			if got := len(srv.Requests()) - before; got != 0 {
+				t.Errorf("FetchSeries() made %d requests, want none", got)
+			}
+		})
+	}
+
+	// Frequencies ending on a weekday are accepted
+	for _, f := range []fred.Frequency{fred.FrequencyWeeklyEndingWednesday, fred.FrequencyBiweeklyEndingWednesday} {
+		opts := fred.FetchOptions{Frequency: f, AggregationMethod: fred.AggregationEndOfPeriod}
+		if err := opts.Validate(); err != nil {
+			t.Errorf("Validate() with frequency %q = %v, want nil", f, err)
+		}
+	}
+}
+
+func TestFetchSeriesReport(t *testing.T) {
+	// FRED marks missing values with "."; the other bad rows are malformed
+	// responses
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"count":5,"offset":0,"limit":100000,"observations":[
+			{"realtime_start":"2021-06-01","realtime_end":"2021-06-01","date":"2020-01-01","value":"1"},
+			{"realtime_start":"2021-06-01","realtime_end":"2021-06-01","date":"2020-02-01","value":"."},
+			{"realtime_start":"2021-06-01","realtime_end":"2021-06-01","date":"2020-13-01","value":"2"},
+			{"realtime_start":"2021-06-01","realtime_end":"2021-06-01","date":"2020-03-01","value":"n/a"},
+			{"realtime_start":"2021-06-01","realtime_end":"2021-06-01","date":"2020-04-01","value":"4.5"}
+		]}`))
+	}))
+	defer srv.Close()
+
+	client := fred.NewClient(fred.WithBaseURL(srv.URL), fred.WithAPIKey("key"), fred.WithLimiter(nil))
+	date := func(month time.Month) time.Time {
+		return time.Date(2020, month, 1, 0, 0, 0, 0, time.UTC)
+	}
+	vintage := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		opts        fred.FetchOptions
+		wantDates   []time.Time
+		wantMissing []bool
+		wantSkipped int
+	}{
+		{
+			name:        "missing dropped",
+			wantDates:   []time.Time{date(1), date(4)},
+			wantMissing: []bool{false, false},
+			wantSkipped: 3,
+		},
+		{
+			name:        "missing kept",
+			opts:        fred.FetchOptions{KeepMissing: true},
+			wantDates:   []time.Time{date(1), date(2), date(3), date(4)},
+			wantMissing: []bool{false, true, true, false},
+			wantSkipped: 1,
+		},
+		{
+			name:        "vintage",
+			opts:        fred.FetchOptions{VintageDates: []time.Time{vintage}},
+			wantDates:   []time.Time{date(1), date(4)},
+			wantMissing: []bool{false, false},
+			wantSkipped: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			series, err := client.FetchSeriesWithReport(context.Background(), "GDP", &tt.opts)
+			if err != nil {
+				t.Fatalf("FetchSeriesWithReport() error = %v", err)
+			}
+
+			if len(series.Points) != len(tt.wantDates) {
+				t.Fatalf("FetchSeriesWithReport() returned %d points, want %d", len(series.Points), len(tt.wantDates))
+			}
+			for i, p := range series.Points {
+				if !p.Date.Equal(tt.wantDates[i]) || p.Missing != tt.wantMissing[i] || p.Missing != math.IsNaN(p.Value) {
+					t.Errorf("point %d = %+v, want date %s and missing %v", i, p, tt.wantDates[i].Format("2006-01-02"), tt.wantMissing[i])
+				}
+				// The real-time period is only parsed when it was asked for
+				if got := !p.RealtimeStart.IsZero(); got != (len(tt.opts.VintageDates) > 0) {
+					t.Errorf("point %d RealtimeStart = %v", i, p.RealtimeStart)
+				}
+			}
+			if got := series.Points[len(series.Points)-1].Value; got != 4.5 {
+				t.Errorf("last value = %v, want 4.5", got)
+			}
+
+			want := fred.FetchReport{
+				SeriesID:     "GDP",
+				Rows:         5,
+				Missing:      1,
+				KeptMissing:  tt.opts.KeepMissing,
+				InvalidDate:  1,
+				InvalidValue: 1,
+			}
+			if series.Report != want {
+				t.Errorf("Report = %+v, want %+v", series.Report, want)
+			}
+			if got := series.Report.Skipped(); got != tt.wantSkipped {
+				t.Errorf("Skipped() = %d, want %d", got, tt.wantSkipped)
+			}
+		})
+	}
+}
+
+func TestFetchSeriesKeepMissing(t *testing.T) {
+	srv := fredtest.NewServer()
+	defer srv.Close()
+
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	srv.AddSeries("GDP", fred.FrequencyQuarterly, fredtest.Points(fred.FrequencyQuarterly, start, 1, math.NaN(), math.NaN(), 4))
+
+	tests := []struct {
+		keepMissing bool
+		want        []float64 // NaN for missing points
+	}{
+		{keepMissing: false, want: []float64{1, 4}},
+		{keepMissing: true, want: []float64{1, math.NaN(), math.NaN(), 4}},
+	}
+
+	for _, tt := range tests {
+		opts := &fred.FetchOptions{Frequency: fred.FrequencyQuarterly, KeepMissing: tt.keepMissing}
+		series, err := srv.Client().FetchSeriesWithReport(context.Background(), "GDP", opts)
+		if err != nil {
+			t.Fatalf("FetchSeriesWithReport(KeepMissing: %v) error = %v", tt.keepMissing, err)
+		}
+
+		values := make([]float64, len(series.Points))
+		for i, p := range series.Points {
+			values[i] = p.Value
+		}
+		if !slices.EqualFunc(values, tt.want, func(a, b float64) bool {
+			return a == b || math.IsNaN(a) && math.IsNaN(b)
+		}) {
+			t.Errorf("FetchSeriesWithReport(KeepMissing: %v) values = %v, want %v", tt.keepMissing, values, tt.want)
+		}
+
+		if series.Report.Rows != 4 || series.Report.Missing != 2 || series.Report.KeptMissing != tt.keepMissing {
+			t.Errorf("FetchSeriesWithReport(KeepMissing: %v) report = %+v, want 4 rows with 2 missing", tt.keepMissing, series.Report)
+		}
+	}
+}
@@ -0,0 +1,657 @@
+// Package fredtest provides an in-process fake of the FRED API for tests.
+//
+// The fake serves fred/series and fred/series/observations from canned data,
+// applying frequency aggregation, units transformations, observation ranges,
+// sort order and limit/offset paging the same way the real API does. Vintage
+// (realtime) parameters are accepted but ignored. Release calendars set with
+// SetRelease are served by fred/series/release and fred/release/dates.
+package fredtest
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/shanehull/shanehull.com/internal/fred"
+)
+
+// APIKey is the key the fake accepts unless Server.APIKey is changed
+const APIKey = "fredtest"
+
+const (
+	dateLayout   = "2006-01-02"
+	defaultLimit = 100000
+)
+
+// Server is a fake FRED API. Create one with NewServer and Close it when
+// done.
+type Server struct {
+	*httptest.Server
+
+	// APIKey is the only api_key the server accepts
+	APIKey string
+
+	mu       sync.Mutex
+	series   map[string]*series
+	releases map[string]fred.Release
+	dates    map[int][]time.Time
+	failures []int
+	requests []*http.Request
+}
+
+type series struct {
+	info      fred.SeriesInfo
+	frequency fred.Frequency
+	points    []fred.DataPoint
+}
+
+// NewServer starts a fake FRED server with no series
+func NewServer() *Server {
+	s := &Server{
+		APIKey:   APIKey,
+		series:   make(map[string]*series),
+		releases: make(map[string]fred.Release),
+		dates:    make(map[int][]time.Time),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/series", s.handleSeries)
+	mux.HandleFunc("/series/observations", s.handleObservations)
+	mux.HandleFunc("/series/release", s.handleSeriesRelease)
+	mux.HandleFunc("/release/dates", s.handleReleaseDates)
+	s.Server = httptest.NewServer(s.intercept(mux))
+
+	return s
+}
+
+// Client returns a fred.Client pointed at the server, with rate limiting
+// disabled and fast retries. opts are applied after the defaults.
+func (s *Server) Client(opts ...fred.Option) *fred.Client {
+	defaults := []fred.Option{
+		fred.WithBaseURL(s.URL),
+		fred.WithAPIKey(s.APIKey),
+		fred.WithHTTPClient(s.Server.Client()),
+		fred.WithLimiter(nil),
+		fred.WithRetryPolicy(fred.RetryPolicy{
+			MaxAttempts: 3,
+			BaseDelay:   time.Millisecond,
+			MaxDelay:    10 * time.Millisecond,
+		}),
+	}
+
+	return fred.NewClient(append(defaults, opts...)...)
+}
+
+// AddSeries registers a series published at the given frequency. Points
+// with Missing set or a NaN value are served as ".". Points need not be
+// sorted.
+func (s *Server) AddSeries(id string, frequency fred.Frequency, points []fred.DataPoint) {
+	sorted := slices.Clone(points)
+	slices.SortFunc(sorted, func(a, b fred.DataPoint) int {
+		return a.Date.Compare(b.Date)
+	})
+
+	info := fred.SeriesInfo{
+		ID:             id,
+		Title:          id,
+		FrequencyShort: string(frequency),
+		Units:          "Levels",
+		UnitsShort:     "Lvl",
+		LastUpdated:    "2025-01-01 00:00:00-06",
+	}
+	if len(sorted) > 0 {
+		info.ObservationStart = sorted[0].Date.Format(dateLayout)
+		info.ObservationEnd = sorted[len(sorted)-1].Date.Format(dateLayout)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.series[id] = &series{info: info, frequency: frequency, points: sorted}
+}
+
+// SetInfo replaces the metadata served by fred/series for a series added
+// with AddSeries. The ID is kept.
+func (s *Server) SetInfo(id string, info fred.SeriesInfo) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if sr, ok := s.series[id]; ok {
+		info.ID = id
+		sr.info = info
+	}
+}
+
+// SetRelease sets the release a series belongs to and the release's dates,
+// which may include dates in the future
+func (s *Server) SetRelease(seriesID string, release fred.Release, dates ...time.Time) {
+	sorted := slices.Clone(dates)
+	slices.SortFunc(sorted, time.Time.Compare)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.releases[seriesID] = release
+	s.dates[release.ID] = sorted
+}
+
+// FailNext makes the next len(statuses) requests fail with the given HTTP
+// statuses, in order, e.g. to exercise retries
+func (s *Server) FailNext(statuses ...int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.failures = append(s.failures, statuses...)
+}
+
+// Requests returns the requests the server has received so far
+func (s *Server) Requests() []*http.Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return slices.Clone(s.requests)
+}
+
+// Points builds consecutive observations starting at start, one per period
+// of the given frequency. NaN values are marked missing.
+func Points(frequency fred.Frequency, start time.Time, values ...float64) []fred.DataPoint {
+	points := make([]fred.DataPoint, len(values))
+	date := start
+	for i, v := range values {
+		points[i] = fred.DataPoint{Date: date, Value: v, Missing: math.IsNaN(v)}
+		date = nextPeriod(date, frequency)
+	}
+
+	return points
+}
+
+// intercept records requests, checks the API key and injects failures
+func (s *Server) intercept(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests = append(s.requests, r.Clone(r.Context()))
+		var status int
+		if len(s.failures) > 0 {
+			status = s.failures[0]
+			s.failures = s.failures[1:]
+		}
+		apiKey := s.APIKey
+		s.mu.Unlock()
+
+		if status != 0 {
+			writeError(w, status, http.StatusText(status))
+			return
+		}
+
+		if r.URL.Query().Get("api_key") != apiKey {
+			writeError(w, http.StatusBadRequest, "Bad Request.  The value for variable api_key is not registered.")
+			return
+		}
+
+		if r.URL.Query().Get("file_type") != "json" {
+			writeError(w, http.StatusBadRequest, "Bad Request.  The fake only serves file_type=json.")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) lookup(id string) (*series, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sr, ok := s.series[id]
+	return sr, ok
+}
+
+func (s *Server) handleSeries(w http.ResponseWriter, r *http.Request) {
+	sr, ok := s.lookup(r.URL.Query().Get("series_id"))
+	if !ok {
+		writeError(w, http.StatusBadRequest, "Bad Request.  The series does not exist.")
+		return
+	}
+
+	writeJSON(w, map[string]any{"seriess": []fred.SeriesInfo{sr.info}})
+}
+
+func (s *Server) handleSeriesRelease(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("series_id")
+	if _, ok := s.lookup(id); !ok {
+		writeError(w, http.StatusBadRequest, "Bad Request.  The series does not exist.")
+		return
+	}
+
+	s.mu.Lock()
+	release, ok := s.releases[id]
+	s.mu.Unlock()
+
+	releases := []fred.Release{}
+	if ok {
+		releases = append(releases, release)
+	}
+	writeJSON(w, map[string]any{"releases": releases})
+}
+
+func (s *Server) handleReleaseDates(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	releaseID, err := strconv.Atoi(query.Get("release_id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Bad Request.  Variable release_id is not an integer.")
+		return
+	}
+
+	s.mu.Lock()
+	dates, ok := s.dates[releaseID]
+	s.mu.Unlock()
+	if !ok {
+		writeError(w, http.StatusBadRequest, "Bad Request.  The release does not exist.")
+		return
+	}
+
+	// Like FRED, default to dates from the start of the current year
+	now := time.Now()
+	start, err := parseDate(query.Get("realtime_start"), time.Date(now.Year(), 1, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Bad Request.  Variable realtime_start can not be parsed.")
+		return
+	}
+	limit, err := parseInt(query.Get("limit"), 10000)
+	if err != nil || limit < 1 {
+		writeError(w, http.StatusBadRequest, "Bad Request.  Variable limit is not a positive integer.")
+		return
+	}
+
+	type releaseDate struct {
+		ReleaseID int    `json:"release_id"`
+		Date      string `json:"date"`
+	}
+	matched := make([]releaseDate, 0, len(dates))
+	for _, d := range dates {
+		if !d.Before(start) {
+			matched = append(matched, releaseDate{ReleaseID: releaseID, Date: d.Format(dateLayout)})
+		}
+	}
+	if query.Get("sort_order") == "desc" {
+		slices.Reverse(matched)
+	}
+
+	writeJSON(w, map[string]any{
+		"count":         len(matched),
+		"offset":        0,
+		"limit":         limit,
+		"release_dates": matched[:min(limit, len(matched))],
+	})
+}
+
+func (s *Server) handleObservations(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	sr, ok := s.lookup(query.Get("series_id"))
+	if !ok {
+		writeError(w, http.StatusBadRequest, "Bad Request.  The series does not exist.")
+		return
+	}
+
+	start, err := parseDate(query.Get("observation_start"), time.Date(1776, 7, 4, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Bad Request.  Variable observation_start can not be parsed.")
+		return
+	}
+	end, err := parseDate(query.Get("observation_end"), time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Bad Request.  Variable observation_end can not be parsed.")
+		return
+	}
+
+	frequency := sr.frequency
+	if f := fred.Frequency(query.Get("frequency")); f != "" {
+		if !f.Valid() || rank(f) < rank(sr.frequency) {
+			writeError(w, http.StatusBadRequest, "Bad Request.  Variable frequency is not one of the allowed values.")
+			return
+		}
+		frequency = f
+	}
+
+	method := fred.AggregationAverage
+	if m := fred.AggregationMethod(query.Get("aggregation_method")); m != "" {
+		if !m.Valid() {
+			writeError(w, http.StatusBadRequest, "Bad Request.  Variable aggregation_method is not one of the allowed values.")
+			return
+		}
+		method = m
+	}
+
+	units := fred.UnitsLevels
+	if u := fred.Units(query.Get("units")); u != "" {
+		if !u.Valid() {
+			writeError(w, http.StatusBadRequest, "Bad Request.  Variable units is not one of the allowed values.")
+			return
+		}
+		units = u
+	}
+
+	sortOrder := query.Get("sort_order")
+	if sortOrder == "" {
+		sortOrder = "asc"
+	}
+	if sortOrder != "asc" && sortOrder != "desc" {
+		writeError(w, http.StatusBadRequest, "Bad Request.  Variable sort_order is not one of the allowed values.")
+		return
+	}
+
+	limit, err := parseInt(query.Get("limit"), defaultLimit)
+	if err != nil || limit < 1 || limit > defaultLimit {
+		writeError(w, http.StatusBadRequest, "Bad Request.  Variable limit is not between 1 and 100000.")
+		return
+	}
+	offset, err := parseInt(query.Get("offset"), 0)
+	if err != nil || offset < 0 {
+		writeError(w, http.StatusBadRequest, "Bad Request.  Variable offset is not a non-negative integer.")
+		return
+	}
+
+	// Like FRED, transform the whole aggregated history before applying the
+	// observation range, so changes at the start of the range are defined
+	points := sr.points
+	if frequency != sr.frequency {
+		points = aggregate(points, frequency, method)
+	}
+	points = transform(points, frequency, units)
+
+	filtered := make([]fred.DataPoint, 0, len(points))
+	for _, p := range points {
+		if !p.Date.Before(start) && !p.Date.After(end) {
+			filtered = append(filtered, p)
+		}
+	}
+	if sortOrder == "desc" {
+		slices.Reverse(filtered)
+	}
+
+	count := len(filtered)
+	page := filtered[min(offset, count):min(offset+limit, count)]
+
+	today := time.Now().Format(dateLayout)
+	observations := make([]fred.Observation, len(page))
+	for i, p := range page {
+		value := "."
+		if !p.Missing && !math.IsNaN(p.Value) {
+			value = strconv.FormatFloat(p.Value, 'f', -1, 64)
+		}
+		observations[i] = fred.Observation{
+			RealtimeStart: today,
+			RealtimeEnd:   today,
+			Date:          p.Date.Format(dateLayout),
+			Value:         value,
+		}
+	}
+
+	writeJSON(w, map[string]any{
+		"realtime_start":    today,
+		"realtime_end":      today,
+		"observation_start": start.Format(dateLayout),
+		"observation_end":   end.Format(dateLayout),
+		"units":             units,
+		"output_type":       1,
+		"file_type":         "json",
+		"order_by":          "observation_date",
+		"sort_order":        sortOrder,
+		"count":             count,
+		"offset":            offset,
+		"limit":             limit,
+		"observations":      observations,
+	})
+}
+
+// aggregate combines points into periods of the given frequency, dated at
+// the start of each period (or the end of the week for weekly frequencies)
+func aggregate(points []fred.DataPoint, frequency fred.Frequency, method fred.AggregationMethod) []fred.DataPoint {
+	var out []fred.DataPoint
+	var values []float64
+	var period time.Time
+
+	flush := func() {
+		if period.IsZero() {
+			return
+		}
+		p := fred.DataPoint{Date: period, Value: math.NaN(), Missing: true}
+		if len(values) > 0 {
+			p.Value = combine(values, method)
+			p.Missing = false
+		}
+		out = append(out, p)
+		values = values[:0]
+	}
+
+	for _, p := range points {
+		if start := periodOf(p.Date, frequency); !start.Equal(period) {
+			flush()
+			period = start
+		}
+		if !p.Missing && !math.IsNaN(p.Value) {
+			values = append(values, p.Value)
+		}
+	}
+	flush()
+
+	return out
+}
+
+func combine(values []float64, method fred.AggregationMethod) float64 {
+	switch method {
+	case fred.AggregationEndOfPeriod:
+		return values[len(values)-1]
+	case fred.AggregationSum:
+		sum := 0.0
+		for _, v := range values {
+			sum += v
+		}
+		return sum
+	default:
+		sum := 0.0
+		for _, v := range values {
+			sum += v
+		}
+		return sum / float64(len(values))
+	}
+}
+
+// transform applies a units transformation. Changes from a year ago look up
+// the observation dated exactly one year earlier.
+func transform(points []fred.DataPoint, frequency fred.Frequency, units fred.Units) []fred.DataPoint {
+	if units == fred.UnitsLevels {
+		return points
+	}
+
+	byDate := make(map[time.Time]float64, len(points))
+	for _, p := range points {
+		if !p.Missing {
+			byDate[p.Date] = p.Value
+		}
+	}
+
+	n := periodsPerYear(frequency)
+	out := make([]fred.DataPoint, len(points))
+	for i, p := range points {
+		prev, prevOK := math.NaN(), false
+		switch units {
+		case fred.UnitsChangeFromYearAgo, fred.UnitsPercentChangeFromYearAgo:
+			prev, prevOK = byDate[p.Date.AddDate(-1, 0, 0)]
+		default:
+			if i > 0 && !points[i-1].Missing {
+				prev, prevOK = points[i-1].Value, true
+			}
+		}
+
+		x := p.Value
+		var v float64
+		switch units {
+		case fred.UnitsNaturalLog:
+			v = math.Log(x)
+		case fred.UnitsChange, fred.UnitsChangeFromYearAgo:
+			v = x - prev
+		case fred.UnitsPercentChange, fred.UnitsPercentChangeFromYearAgo:
+			v = (x/prev - 1) * 100
+		case fred.UnitsPercentChangeAnnualRate:
+			v = (math.Pow(x/prev, n) - 1) * 100
+		case fred.UnitsContinuouslyCompoundedRate:
+			v = math.Log(x/prev) * 100
+		case fred.UnitsContinuouslyCompoundedAnnualRate:
+			v = math.Log(x/prev) * 100 * n
+		}
+
+		missing := p.Missing || (units != fred.UnitsNaturalLog && !prevOK) ||
+			math.IsNaN(v) || math.IsInf(v, 0)
+		if missing {
+			v = math.NaN()
+		}
+		out[i] = fred.DataPoint{Date: p.Date, Value: v, Missing: missing}
+	}
+
+	return out
+}
+
+// rank orders frequencies from highest to lowest
+func rank(f fred.Frequency) int {
+	switch f {
+	case fred.FrequencyDaily:
+		return 0
+	case fred.FrequencyBiweekly, fred.FrequencyBiweeklyEndingWednesday, fred.FrequencyBiweeklyEndingMonday:
+		return 2
+	case fred.FrequencyMonthly:
+		return 3
+	case fred.FrequencyQuarterly:
+		return 4
+	case fred.FrequencySemiannual:
+		return 5
+	case fred.FrequencyAnnual:
+		return 6
+	default:
+		return 1
+	}
+}
+
+func periodsPerYear(f fred.Frequency) float64 {
+	switch rank(f) {
+	case 0:
+		return 260
+	case 1:
+		return 52
+	case 2:
+		return 26
+	case 3:
+		return 12
+	case 4:
+		return 4
+	case 5:
+		return 2
+	default:
+		return 1
+	}
+}
+
+// weekEnd is the day each weekly frequency ends on
+func weekEnd(f fred.Frequency) time.Weekday {
+	switch f {
+	case fred.FrequencyWeeklyEndingThursday:
+		return time.Thursday
+	case fred.FrequencyWeeklyEndingWednesday, fred.FrequencyBiweekly, fred.FrequencyBiweeklyEndingWednesday:
+		return time.Wednesday
+	case fred.FrequencyWeeklyEndingTuesday:
+		return time.Tuesday
+	case fred.FrequencyWeeklyEndingMonday, fred.FrequencyBiweeklyEndingMonday:
+		return time.Monday
+	case fred.FrequencyWeeklyEndingSunday:
+		return time.Sunday
+	case fred.FrequencyWeeklyEndingSaturday:
+		return time.Saturday
+	default:
+		return time.Friday
+	}
+}
+
+// periodOf returns the date FRED uses for the period containing t
+func periodOf(t time.Time, f fred.Frequency) time.Time {
+	t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+
+	switch rank(f) {
+	case 0:
+		return t
+	case 1, 2:
+		end := t.AddDate(0, 0, (int(weekEnd(f))-int(t.Weekday())+7)%7)
+		if rank(f) == 2 {
+			// Biweekly periods are anchored to the first week ending on
+			// the given day in 1970
+			anchor := time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)
+			anchor = anchor.AddDate(0, 0, (int(weekEnd(f))-int(anchor.Weekday())+7)%7)
+			if int(end.Sub(anchor).Hours()/24/7)%2 != 0 {
+				end = end.AddDate(0, 0, 7)
+			}
+		}
+		return end
+	case 3:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	case 4:
+		return time.Date(t.Year(), (t.Month()-1)/3*3+1, 1, 0, 0, 0, 0, time.UTC)
+	case 5:
+		return time.Date(t.Year(), (t.Month()-1)/6*6+1, 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return time.Date(t.Year(), 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+}
+
+// nextPeriod steps t forward by one period of the given frequency
+func nextPeriod(t time.Time, f fred.Frequency) time.Time {
+	switch rank(f) {
+	case 0:
+		return t.AddDate(0, 0, 1)
+	case 1:
+		return t.AddDate(0, 0, 7)
+	case 2:
+		return t.AddDate(0, 0, 14)
+	case 3:
+		return t.AddDate(0, 1, 0)
+	case 4:
+		return t.AddDate(0, 3, 0)
+	case 5:
+		return t.AddDate(0, 6, 0)
+	default:
+		return t.AddDate(1, 0, 0)
+	}
+}
+
+func parseDate(s string, fallback time.Time) (time.Time, error) {
+	if s == "" {
+		return fallback, nil
+	}
+	return time.Parse(dateLayout, s)
+}
+
+func parseInt(s string, fallback int) (int, error) {
+	if s == "" {
+		return fallback, nil
+	}
+	return strconv.Atoi(s)
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes an error in the format FRED uses
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"error_code":    status,
+		"error_message": message,
+	})
+}
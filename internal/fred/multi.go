@@ -0,0 +1,100 @@
+package fred
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// SeriesRequest is a series to fetch with FetchMany
+type SeriesRequest struct {
+	ID      string
+	Options *FetchOptions
+}
+
+// FetchError reports the series that failed in a FetchMany call. It unwraps
+// to the individual errors, so errors.Is and errors.As see through it.
+type FetchError struct {
+	// Errors holds the error for each failed series, keyed by series ID
+	Errors map[string]error
+}
+
+// Failed returns the IDs of the series that failed, sorted
+func (e *FetchError) Failed() []string {
+	ids := make([]string, 0, len(e.Errors))
+	for id := range e.Errors {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+func (e *FetchError) Error() string {
+	ids := e.Failed()
+	msgs := make([]string, len(ids))
+	for i, id := range ids {
+		msgs[i] = e.Errors[id].Error()
+	}
+	return fmt.Sprintf("%d FRED series failed (%s): %s", len(ids), strings.Join(ids, ", "), strings.Join(msgs, "; "))
+}
+
+func (e *FetchError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Errors))
+	for _, id := range e.Failed() {
+		errs = append(errs, e.Errors[id])
+	}
+	return errs
+}
+
+// FetchMany fetches several series concurrently under a shared context and
+// the client's rate limiter. Results, including each series' fetch report,
+// are keyed by series ID. If any series fails, the others are still returned
+// alongside a *FetchError naming the failures.
+func (c *Client) FetchMany(ctx context.Context, requests []SeriesRequest) (map[string]*Series, error) {
+	return FetchManyWith(ctx, requests, func(ctx context.Context, req SeriesRequest) (*Series, error) {
+		return c.FetchSeriesWithReport(ctx, req.ID, req.Options)
+	})
+}
+
+// SeriesFetcher fetches a single series for FetchManyWith
+type SeriesFetcher func(ctx context.Context, req SeriesRequest) (*Series, error)
+
+// FetchManyWith is FetchMany with each series fetched by fetch, e.g. through
+// a cache in front of a Client. fetch is called concurrently.
+func FetchManyWith(ctx context.Context, requests []SeriesRequest, fetch SeriesFetcher) (map[string]*Series, error) {
+	for i, req := range requests {
+		if slices.ContainsFunc(requests[:i], func(r SeriesRequest) bool { return r.ID == req.ID }) {
+			return nil, fmt.Errorf("series %s requested more than once", req.ID)
+		}
+	}
+
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		results = make(map[string]*Series, len(requests))
+		errs    = make(map[string]error)
+	)
+
+	for _, req := range requests {
+		wg.Go(func() {
+			series, err := fetch(ctx, req)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs[req.ID] = err
+				return
+			}
+			results[req.ID] = series
+		})
+	}
+	wg.Wait()
+
+	if len(errs) > 0 {
+		return results, &FetchError{Errors: errs}
+	}
+
+	return results, nil
+}
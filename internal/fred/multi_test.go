@@ -0,0 +1,59 @@
+package fred_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/shanehull/shanehull.com/internal/fred"
+	"github.com/shanehull/shanehull.com/internal/fred/fredtest"
+)
+
+func TestFetchMany(t *testing.T) {
+	srv := fredtest.NewServer()
+	defer srv.Close()
+
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	srv.AddSeries("GDP", fred.FrequencyQuarterly, fredtest.Points(fred.FrequencyQuarterly, start, 1, 2, 3))
+	srv.AddSeries("CPIAUCNS", fred.FrequencyMonthly, fredtest.Points(fred.FrequencyMonthly, start, 4, 5))
+
+	quarterly := &fred.FetchOptions{Frequency: fred.FrequencyQuarterly}
+	monthly := &fred.FetchOptions{Frequency: fred.FrequencyMonthly}
+	results, err := srv.Client().FetchMany(context.Background(), []fred.SeriesRequest{
+		{ID: "GDP", Options: quarterly},
+		{ID: "CPIAUCNS", Options: monthly},
+		{ID: "MISSING", Options: monthly},
+	})
+
+	var fetchErr *fred.FetchError
+	if !errors.As(err, &fetchErr) {
+		t.Fatalf("FetchMany() error = %v, want a *FetchError", err)
+	}
+	if got, want := fetchErr.Failed(), []string{"MISSING"}; !slices.Equal(got, want) {
+		t.Errorf("Failed() = %v, want %v", got, want)
+	}
+
+	// The series that didn't fail are still returned
+	if got := len(results["GDP"].Points); got != 3 {
+		t.Errorf("GDP has %d points, want 3", got)
+	}
+	if got := len(results["CPIAUCNS"].Points); got != 2 {
+		t.Errorf("CPIAUCNS has %d points, want 2", got)
+	}
+	if _, ok := results["MISSING"]; ok {
+		t.Error("results include the failed series")
+	}
+}
+
+func TestFetchManyDuplicate(t *testing.T) {
+	_, err := fred.FetchManyWith(context.Background(), []fred.SeriesRequest{{ID: "GDP"}, {ID: "GDP"}},
+		func(context.Context, fred.SeriesRequest) (*fred.Series, error) {
+			t.Error("fetch called for a duplicate request")
+			return nil, nil
+		})
+	if err == nil {
+		t.Error("FetchManyWith() error = nil, want an error for a duplicate series")
+	}
+}
@@ -0,0 +1,84 @@
+package fred
+
+import "slices"
+
+// Frequency is the frequency FRED aggregates observations to
+type Frequency string
+
+const (
+	FrequencyDaily      Frequency = "d"
+	FrequencyWeekly     Frequency = "w"
+	FrequencyBiweekly   Frequency = "bw"
+	FrequencyMonthly    Frequency = "m"
+	FrequencyQuarterly  Frequency = "q"
+	FrequencySemiannual Frequency = "sa"
+	FrequencyAnnual     Frequency = "a"
+
+	// Weekly and biweekly periods ending on a given day of the week
+	FrequencyWeeklyEndingFriday      Frequency = "wef"
+	FrequencyWeeklyEndingThursday    Frequency = "weth"
+	FrequencyWeeklyEndingWednesday   Frequency = "wew"
+	FrequencyWeeklyEndingTuesday     Frequency = "wetu"
+	FrequencyWeeklyEndingMonday      Frequency = "wem"
+	FrequencyWeeklyEndingSunday      Frequency = "wesu"
+	FrequencyWeeklyEndingSaturday    Frequency = "wesa"
+	FrequencyBiweeklyEndingWednesday Frequency = "bwew"
+	FrequencyBiweeklyEndingMonday    Frequency = "bwem"
+)
+
+var frequencies = []Frequency{
+	FrequencyDaily, FrequencyWeekly, FrequencyBiweekly, FrequencyMonthly,
+	FrequencyQuarterly, FrequencySemiannual, FrequencyAnnual,
+	FrequencyWeeklyEndingFriday, FrequencyWeeklyEndingThursday,
+	FrequencyWeeklyEndingWednesday, FrequencyWeeklyEndingTuesday,
+	FrequencyWeeklyEndingMonday, FrequencyWeeklyEndingSunday,
+	FrequencyWeeklyEndingSaturday, FrequencyBiweeklyEndingWednesday,
+	FrequencyBiweeklyEndingMonday,
+}
+
+// Valid reports whether f is a frequency FRED accepts
+func (f Frequency) Valid() bool {
+	return slices.Contains(frequencies, f)
+}
+
+// Units is the transformation FRED applies to observations
+type Units string
+
+const (
+	UnitsLevels                           Units = "lin"
+	UnitsChange                           Units = "chg"
+	UnitsChangeFromYearAgo                Units = "ch1"
+	UnitsPercentChange                    Units = "pch"
+	UnitsPercentChangeFromYearAgo         Units = "pc1"
+	UnitsPercentChangeAnnualRate          Units = "pca"
+	UnitsContinuouslyCompoundedRate       Units = "cch"
+	UnitsContinuouslyCompoundedAnnualRate Units = "cca"
+	UnitsNaturalLog                       Units = "log"
+)
+
+var units = []Units{
+	UnitsLevels, UnitsChange, UnitsChangeFromYearAgo, UnitsPercentChange,
+	UnitsPercentChangeFromYearAgo, UnitsPercentChangeAnnualRate,
+	UnitsContinuouslyCompoundedRate, UnitsContinuouslyCompoundedAnnualRate,
+	UnitsNaturalLog,
+}
+
+// Valid reports whether u is a units transformation FRED accepts
+func (u Units) Valid() bool {
+	return slices.Contains(units, u)
+}
+
+// AggregationMethod is how FRED combines observations when aggregating to a
+// lower frequency
+type AggregationMethod string
+
+const (
+	AggregationAverage     AggregationMethod = "avg"
+	AggregationSum         AggregationMethod = "sum"
+	AggregationEndOfPeriod AggregationMethod = "eop"
+)
+
+// Valid reports whether m is an aggregation method FRED accepts
+func (m AggregationMethod) Valid() bool {
+	return m == AggregationAverage || m == AggregationSum || m == AggregationEndOfPeriod
+}
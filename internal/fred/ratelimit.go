@@ -0,0 +1,80 @@
+package fred
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// FRED allows 120 requests per minute per API key. The default limiter stays
+// a little under that so bursts on a cold cache don't get throttled.
+const (
+	defaultRateLimit = 100
+	defaultBurst     = 10
+)
+
+// Limiter is a token bucket rate limiter. A single Limiter can be shared by
+// several clients using the same API key.
+type Limiter struct {
+	mu       sync.Mutex
+	tokens   float64
+	burst    float64
+	interval time.Duration // time to refill one token
+	last     time.Time
+}
+
+// NewLimiter returns a Limiter allowing limit requests per period, with up to
+// burst requests allowed at once.
+func NewLimiter(limit int, per time.Duration, burst int) *Limiter {
+	if limit < 1 {
+		limit = 1
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &Limiter{
+		tokens:   float64(burst),
+		burst:    float64(burst),
+		interval: per / time.Duration(limit),
+		last:     time.Now(),
+	}
+}
+
+// Wait blocks until a request may be made or ctx is done.
+func (l *Limiter) Wait(ctx context.Context) error {
+	for {
+		delay := l.reserve()
+		if delay == 0 {
+			return nil
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// reserve takes a token if one is available and returns zero, otherwise it
+// returns how long until the next token is due.
+func (l *Limiter) reserve() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.tokens += float64(now.Sub(l.last)) / float64(l.interval)
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+
+	if l.tokens >= 1 {
+		l.tokens--
+		return 0
+	}
+
+	return time.Duration((1 - l.tokens) * float64(l.interval))
+}
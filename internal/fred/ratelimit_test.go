@@ -0,0 +1,66 @@
+package fred
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLimiter(t *testing.T) {
+	// 10 requests a second, so a token every 100ms, with a burst of 2
+	l := NewLimiter(10, time.Second, 2)
+
+	// elapse winds the limiter's clock back, as though d had passed
+	elapse := func(d time.Duration) {
+		l.mu.Lock()
+		l.last = l.last.Add(-d)
+		l.mu.Unlock()
+	}
+
+	for i := range 2 {
+		if delay := l.reserve(); delay != 0 {
+			t.Fatalf("reserve() %d within the burst = %v, want 0", i+1, delay)
+		}
+	}
+	if delay := l.reserve(); delay <= 0 || delay > 100*time.Millisecond {
+		t.Errorf("reserve() after the burst = %v, want up to 100ms", delay)
+	}
+
+	// Half a token has accrued, so the wait is halved
+	elapse(50 * time.Millisecond)
+	if delay := l.reserve(); delay <= 0 || delay > 50*time.Millisecond {
+		t.Errorf("reserve() half a token later = %v, want up to 50ms", delay)
+	}
+
+	elapse(100 * time.Millisecond)
+	if delay := l.reserve(); delay != 0 {
+		t.Errorf("reserve() a token later = %v, want 0", delay)
+	}
+
+	// Idle time refills the bucket up to the burst only
+	elapse(time.Hour)
+	for i := range 2 {
+		if delay := l.reserve(); delay != 0 {
+			t.Fatalf("reserve() %d after idling = %v, want 0", i+1, delay)
+		}
+	}
+	if delay := l.reserve(); delay == 0 {
+		t.Error("reserve() beyond the burst after idling = 0, want a delay")
+	}
+}
+
+func TestLimiterWait(t *testing.T) {
+	l := NewLimiter(1, time.Hour, 1)
+
+	if err := l.Wait(context.Background()); err != nil {
+		t.Fatalf("Wait() with a token = %v, want nil", err)
+	}
+
+	// The next token is an hour away, so Wait returns when ctx is done
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := l.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Wait() without a token = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
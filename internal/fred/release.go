@@ -0,0 +1,93 @@
+package fred
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	seriesReleasePath = "/series/release"
+	releaseDatesPath  = "/release/dates"
+
+	// releaseDatesLimit covers a year of daily releases
+	releaseDatesLimit = 1000
+)
+
+// Release is a FRED release, the publication a series' data comes from
+type Release struct {
+	ID           int    `json:"id"`
+	Name         string `json:"name"`
+	PressRelease bool   `json:"press_release"`
+	Link         string `json:"link"`
+}
+
+// releasesResponse represents the FRED fred/series/release response
+type releasesResponse struct {
+	Releases []Release `json:"releases"`
+}
+
+// releaseDatesResponse represents the FRED fred/release/dates response
+type releaseDatesResponse struct {
+	ReleaseDates []struct {
+		ReleaseID int    `json:"release_id"`
+		Date      string `json:"date"`
+	} `json:"release_dates"`
+}
+
+// FetchSeriesRelease retrieves the release a series belongs to
+func (c *Client) FetchSeriesRelease(ctx context.Context, seriesID string) (*Release, error) {
+	query := url.Values{}
+	query.Set("series_id", seriesID)
+
+	var resp releasesResponse
+	if err := c.get(ctx, seriesReleasePath, query, &resp); err != nil {
+		return nil, fmt.Errorf("failed to fetch FRED release for series %s: %w", seriesID, err)
+	}
+
+	if len(resp.Releases) == 0 {
+		return nil, fmt.Errorf("no release for series %s: %w", seriesID, ErrNoScheduledRelease)
+	}
+
+	return &resp.Releases[0], nil
+}
+
+// FetchReleaseDates retrieves the dates of a release from the start of the
+// current year, including dates scheduled in the future, in ascending order
+func (c *Client) FetchReleaseDates(ctx context.Context, releaseID int) ([]time.Time, error) {
+	query := url.Values{}
+	query.Set("release_id", strconv.Itoa(releaseID))
+	query.Set("include_release_dates_with_no_data", "true")
+	query.Set("sort_order", "asc")
+	query.Set("limit", strconv.Itoa(releaseDatesLimit))
+
+	var resp releaseDatesResponse
+	if err := c.get(ctx, releaseDatesPath, query, &resp); err != nil {
+		return nil, fmt.Errorf("failed to fetch FRED release dates for release %d: %w", releaseID, err)
+	}
+
+	dates := make([]time.Time, 0, len(resp.ReleaseDates))
+	for _, rd := range resp.ReleaseDates {
+		date, err := time.Parse("2006-01-02", rd.Date)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse FRED release date %q: %w", rd.Date, err)
+		}
+		dates = append(dates, date)
+	}
+
+	return dates, nil
+}
+
+// FetchSeriesReleaseDates retrieves the dates of the release a series
+// belongs to, as FetchReleaseDates does. Release dates are calendar dates
+// with no time of day.
+func (c *Client) FetchSeriesReleaseDates(ctx context.Context, seriesID string) ([]time.Time, error) {
+	release, err := c.FetchSeriesRelease(ctx, seriesID)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.FetchReleaseDates(ctx, release.ID)
+}
@@ -0,0 +1,84 @@
+package fred
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		want   time.Duration
+	}{
+		{name: "absent", header: "", want: 0},
+		{name: "seconds", header: "3", want: 3 * time.Second},
+		{name: "zero seconds", header: "0", want: 0},
+		{name: "negative seconds", header: "-5", want: 0},
+		{name: "invalid", header: "soon", want: 0},
+		{name: "date in the past", header: time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseRetryAfter(tt.header); got != tt.want {
+				t.Errorf("parseRetryAfter(%q) = %v, want %v", tt.header, got, tt.want)
+			}
+		})
+	}
+
+	// HTTP dates have a resolution of a second
+	header := time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)
+	if got := parseRetryAfter(header); got < 58*time.Second || got > time.Minute {
+		t.Errorf("parseRetryAfter(%q) = %v, want about a minute", header, got)
+	}
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	policy := RetryPolicy{
+		MaxAttempts: 4,
+		BaseDelay:   100 * time.Millisecond,
+		MaxDelay:    time.Second,
+	}
+
+	tests := []struct {
+		name       string
+		retry      int
+		retryAfter time.Duration
+		min, max   time.Duration
+	}{
+		{name: "first retry", retry: 1, min: 50 * time.Millisecond, max: 100 * time.Millisecond},
+		{name: "doubles", retry: 3, min: 200 * time.Millisecond, max: 400 * time.Millisecond},
+		{name: "capped backoff", retry: 10, min: 500 * time.Millisecond, max: time.Second},
+		{name: "overflowing backoff", retry: 100, min: 500 * time.Millisecond, max: time.Second},
+		{name: "retry after", retry: 1, retryAfter: 300 * time.Millisecond, min: 300 * time.Millisecond, max: 300 * time.Millisecond},
+		{name: "capped retry after", retry: 1, retryAfter: time.Hour, min: time.Second, max: time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Jitter is random, so check the bounds repeatedly
+			for range 100 {
+				if got := policy.delay(tt.retry, tt.retryAfter); got < tt.min || got > tt.max {
+					t.Fatalf("delay(%d, %v) = %v, want between %v and %v", tt.retry, tt.retryAfter, got, tt.min, tt.max)
+				}
+			}
+		})
+	}
+}
+
+func TestRetryableStatus(t *testing.T) {
+	for status, want := range map[int]bool{
+		http.StatusBadRequest:          false,
+		http.StatusNotFound:            false,
+		http.StatusTooManyRequests:     true,
+		http.StatusInternalServerError: true,
+		http.StatusBadGateway:          true,
+		http.StatusServiceUnavailable:  true,
+	} {
+		if got := retryableStatus(status); got != want {
+			t.Errorf("retryableStatus(%d) = %v, want %v", status, got, want)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package fred
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+const seriesPath = "/series"
+
+// SeriesInfo is the metadata FRED publishes for a series
+type SeriesInfo struct {
+	ID                      string `json:"id"`
+	Title                   string `json:"title"`
+	ObservationStart        string `json:"observation_start"`
+	ObservationEnd          string `json:"observation_end"`
+	Frequency               string `json:"frequency"`
+	FrequencyShort          string `json:"frequency_short"`
+	Units                   string `json:"units"`
+	UnitsShort              string `json:"units_short"`
+	SeasonalAdjustment      string `json:"seasonal_adjustment"`
+	SeasonalAdjustmentShort string `json:"seasonal_adjustment_short"`
+	LastUpdated             string `json:"last_updated"`
+	Popularity              int    `json:"popularity"`
+	Notes                   string `json:"notes"`
+}
+
+// seriesResponse represents the FRED fred/series response
+type seriesResponse struct {
+	Seriess []SeriesInfo `json:"seriess"`
+}
+
+// LastUpdatedTime parses LastUpdated, which FRED reports with a short UTC
+// offset, e.g. "2025-06-26 07:58:12-05".
+func (s SeriesInfo) LastUpdatedTime() (time.Time, error) {
+	return time.Parse("2006-01-02 15:04:05-07", s.LastUpdated)
+}
+
+// FetchSeriesInfo retrieves the metadata for a given FRED series ID
+func (c *Client) FetchSeriesInfo(ctx context.Context, seriesID string) (*SeriesInfo, error) {
+	query := url.Values{}
+	query.Set("series_id", seriesID)
+
+	var resp seriesResponse
+	if err := c.get(ctx, seriesPath, query, &resp); err != nil {
+		return nil, fmt.Errorf("failed to fetch FRED series info %s: %w", seriesID, err)
+	}
+
+	if len(resp.Seriess) == 0 {
+		return nil, fmt.Errorf("no metadata for series %s: %w", seriesID, ErrSeriesNotFound)
+	}
+
+	return &resp.Seriess[0], nil
+}
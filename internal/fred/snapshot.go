@@ -0,0 +1,163 @@
+package fred
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Mode selects where a client gets its data from
+type Mode string
+
+const (
+	// ModeLive fetches from the FRED API
+	ModeLive Mode = "live"
+
+	// ModeRecord fetches from the FRED API and writes every response to the
+	// snapshot store
+	ModeRecord Mode = "record"
+
+	// ModeReplay serves responses from the snapshot store only, without an
+	// API key or network access
+	ModeReplay Mode = "replay"
+)
+
+// ParseMode parses a mode name such as the FRED_MODE environment variable.
+// An empty string means ModeLive.
+func ParseMode(s string) (Mode, error) {
+	switch mode := Mode(strings.ToLower(strings.TrimSpace(s))); mode {
+	case "":
+		return ModeLive, nil
+	case ModeLive, ModeRecord, ModeReplay:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown FRED mode %q, expected live, record or replay", s)
+	}
+}
+
+// ErrNoSnapshot is returned in replay mode when no response was recorded for
+// a request
+var ErrNoSnapshot = errors.New("fred: no snapshot recorded")
+
+// SnapshotStore is a file-backed store of recorded FRED API responses. Each
+// response is a JSON file in a directory named after its series (or
+// endpoint), e.g. dir/GDP/3f2a9c01d4e5b6a7.json.
+type SnapshotStore struct {
+	dir string
+}
+
+// snapshot is the on-disk format of a recorded response
+type snapshot struct {
+	Path       string          `json:"path"`
+	Query      string          `json:"query"`
+	RecordedAt time.Time       `json:"recorded_at"`
+	Body       json.RawMessage `json:"body"`
+}
+
+// NewSnapshotStore returns a store rooted at dir. The directory is created
+// when the first snapshot is saved.
+func NewSnapshotStore(dir string) *SnapshotStore {
+	return &SnapshotStore{dir: dir}
+}
+
+// WithSnapshots reads from or writes through to store according to mode.
+// ModeLive leaves the store unused.
+func WithSnapshots(store *SnapshotStore, mode Mode) Option {
+	return func(c *Client) {
+		c.snapshots = store
+		c.mode = mode
+	}
+}
+
+// Load returns the recorded response body for a request
+func (s *SnapshotStore) Load(path string, query url.Values) ([]byte, error) {
+	canonical := snapshotQuery(query)
+
+	data, err := os.ReadFile(s.file(path, query, canonical))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w for %s?%s", ErrNoSnapshot, path, canonical)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot: %w", err)
+	}
+
+	var snap snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return nil, fmt.Errorf("failed to parse snapshot: %w", err)
+	}
+
+	return snap.Body, nil
+}
+
+// Save records the response body for a request
+func (s *SnapshotStore) Save(path string, query url.Values, body []byte) error {
+	canonical := snapshotQuery(query)
+	file := s.file(path, query, canonical)
+
+	data, err := json.MarshalIndent(snapshot{
+		Path:       path,
+		Query:      canonical,
+		RecordedAt: time.Now().UTC(),
+		Body:       body,
+	}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode snapshot: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		return fmt.Errorf("failed to create snapshot dir: %w", err)
+	}
+
+	// Write to a temp file first so a crash never leaves a partial snapshot
+	tmp := file + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	if err := os.Rename(tmp, file); err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+
+	return nil
+}
+
+var unsafePathChars = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+// file returns the snapshot file for a request
+func (s *SnapshotStore) file(path string, query url.Values, canonical string) string {
+	group := query.Get("series_id")
+	if group == "" {
+		group = strings.Trim(path, "/")
+	}
+	group = unsafePathChars.ReplaceAllString(group, "_")
+
+	sum := sha256.Sum256([]byte(path + "?" + canonical))
+	return filepath.Join(s.dir, group, hex.EncodeToString(sum[:8])+".json")
+}
+
+// snapshotQuery returns the canonical form of a request's query used to key
+// snapshots. Credentials are dropped, as is an observation_end of today, so
+// an open-ended request recorded one day still matches the next.
+func snapshotQuery(query url.Values) string {
+	canonical := url.Values{}
+	for k, v := range query {
+		switch k {
+		case "api_key", "file_type":
+			continue
+		}
+		canonical[k] = v
+	}
+	if canonical.Get("observation_end") == time.Now().Format("2006-01-02") {
+		canonical.Del("observation_end")
+	}
+	// Encode sorts by key
+	return canonical.Encode()
+}
@@ -0,0 +1,123 @@
+package fred_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/shanehull/shanehull.com/internal/fred"
+	"github.com/shanehull/shanehull.com/internal/fred/fredtest"
+)
+
+func TestRecordReplay(t *testing.T) {
+	srv := fredtest.NewServer()
+	defer srv.Close()
+
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	srv.AddSeries("GDP", fred.FrequencyQuarterly, fredtest.Points(fred.FrequencyQuarterly, start, 1, 2, 3))
+
+	store := fred.NewSnapshotStore(t.TempDir())
+	opts := &fred.FetchOptions{Frequency: fred.FrequencyQuarterly}
+
+	recorded, err := srv.Client(fred.WithSnapshots(store, fred.ModeRecord)).FetchSeries(context.Background(), "GDP", opts)
+	if err != nil {
+		t.Fatalf("FetchSeries() while recording error = %v", err)
+	}
+
+	// Replay needs neither an API key nor the server
+	t.Setenv("FRED_API_KEY", "")
+	srv.Close()
+	client := fred.NewClient(fred.WithSnapshots(store, fred.ModeReplay))
+
+	replayed, err := client.FetchSeries(context.Background(), "GDP", opts)
+	if err != nil {
+		t.Fatalf("FetchSeries() while replaying error = %v", err)
+	}
+	if !slices.Equal(replayed, recorded) {
+		t.Errorf("replayed %v, want %v", replayed, recorded)
+	}
+
+	// Requests that were never recorded fail rather than going live
+	tests := []struct {
+		name string
+		id   string
+		opts *fred.FetchOptions
+	}{
+		{name: "other series", id: "UNRATE", opts: opts},
+		{name: "other options", id: "GDP", opts: &fred.FetchOptions{Frequency: fred.FrequencyAnnual}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := client.FetchSeries(context.Background(), tt.id, tt.opts); !errors.Is(err, fred.ErrNoSnapshot) {
+				t.Errorf("FetchSeries() error = %v, want %v", err, fred.ErrNoSnapshot)
+			}
+		})
+	}
+}
+
+func TestSnapshotQuery(t *testing.T) {
+	today := time.Now().Format("2006-01-02")
+
+	tests := []struct {
+		name     string
+		saved    url.Values
+		loaded   url.Values
+		wantSame bool
+	}{
+		{
+			name:     "credentials ignored",
+			saved:    url.Values{"series_id": {"GDP"}, "api_key": {"secret"}, "file_type": {"json"}},
+			loaded:   url.Values{"series_id": {"GDP"}},
+			wantSame: true,
+		},
+		{
+			name:     "observation end of today dropped",
+			saved:    url.Values{"series_id": {"GDP"}, "observation_end": {today}},
+			loaded:   url.Values{"series_id": {"GDP"}},
+			wantSame: true,
+		},
+		{
+			name:     "past observation end kept",
+			saved:    url.Values{"series_id": {"GDP"}, "observation_end": {"2020-01-01"}},
+			loaded:   url.Values{"series_id": {"GDP"}},
+			wantSame: false,
+		},
+		{
+			name:     "other params kept",
+			saved:    url.Values{"series_id": {"GDP"}, "units": {"pc1"}},
+			loaded:   url.Values{"series_id": {"GDP"}, "units": {"lin"}},
+			wantSame: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := fred.NewSnapshotStore(t.TempDir())
+			body := []byte(`{"count":0,"observations":[]}`)
+			if err := store.Save("/series/observations", tt.saved, body); err != nil {
+				t.Fatalf("Save() error = %v", err)
+			}
+
+			got, err := store.Load("/series/observations", tt.loaded)
+			if !tt.wantSame {
+				if !errors.Is(err, fred.ErrNoSnapshot) {
+					t.Errorf("Load() error = %v, want %v", err, fred.ErrNoSnapshot)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			// The stored body is indented along with the rest of the file
+			var compact bytes.Buffer
+			if err := json.Compact(&compact, got); err != nil || compact.String() != string(body) {
+				t.Errorf("Load() = %s, want %s", got, body)
+			}
+		})
+	}
+}
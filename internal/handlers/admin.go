@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/shanehull/shanehull.com/internal/cache"
+	"github.com/shanehull/shanehull.com/internal/fred"
+)
+
+// adminCacheEntry describes a cached entry in the admin listing
+type adminCacheEntry struct {
+	Key       string    `json:"key"`
+	UpdatedAt time.Time `json:"updated_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Age       string    `json:"age"`
+	ExpiresIn string    `json:"expires_in"`
+	Size      int64     `json:"size"`
+	Stale     bool      `json:"stale"`
+}
+
+// adminCache is the admin listing of one cache
+type adminCache struct {
+	Stats   cache.Stats       `json:"stats"`
+	Entries []adminCacheEntry `json:"entries"`
+}
+
+// adminEntries converts cache entry info for the admin listing
+func adminEntries(infos []cache.EntryInfo[string], now time.Time) []adminCacheEntry {
+	entries := make([]adminCacheEntry, len(infos))
+	for i, info := range infos {
+		entries[i] = adminCacheEntry{
+			Key:       info.Key,
+			UpdatedAt: info.UpdatedAt,
+			ExpiresAt: info.ExpiresAt,
+			Age:       now.Sub(info.UpdatedAt).Round(time.Second).String(),
+			ExpiresIn: info.ExpiresAt.Sub(now).Round(time.Second).String(),
+			Size:      info.Size,
+			Stale:     info.Stale,
+		}
+	}
+	return entries
+}
+
+// AdminCacheHandler lists the entries and stats of the chart caches
+func (h *ChartHandlers) AdminCacheHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	now := time.Now()
+	writeAdminJSON(w, http.StatusOK, map[string]adminCache{
+		"series": {
+			Stats:   h.seriesCache.Stats(),
+			Entries: adminEntries(h.seriesCache.Entries(), now),
+		},
+		"custom": {
+			Stats:   h.customCache.Stats(),
+			Entries: adminEntries(h.customCache.Entries(), now),
+		},
+		"sources": {
+			Stats:   h.sourcesCache.Stats(),
+			Entries: adminEntries(h.sourcesCache.Entries(), now),
+		},
+		"release": {
+			Stats:   h.releaseCache.Stats(),
+			Entries: adminEntries(h.releaseCache.Entries(), now),
+		},
+	})
+}
+
+// AdminCachePurgeHandler deletes the entries whose keys start with the
+// prefix query param from the series, custom, sources and release caches, or
+// only from the one named by the cache query param. An empty prefix purges
+// everything.
+func (h *ChartHandlers) AdminCachePurgeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	if !query.Has("prefix") {
+		http.Error(w, "Missing prefix", http.StatusBadRequest)
+		return
+	}
+	prefix := query.Get("prefix")
+	match := func(key string) bool {
+		return strings.HasPrefix(key, prefix)
+	}
+
+	purgers := map[string]func(match func(string) bool) int{
+		"series":  h.seriesCache.DeleteFunc,
+		"custom":  h.customCache.DeleteFunc,
+		"sources": h.sourcesCache.DeleteFunc,
+		"release": h.releaseCache.DeleteFunc,
+	}
+
+	purged := make(map[string]int)
+	if name := query.Get("cache"); name != "" {
+		purge, ok := purgers[name]
+		if !ok {
+			http.Error(w, "Unknown cache", http.StatusBadRequest)
+			return
+		}
+		purged[name] = purge(match)
+	} else {
+		for name, purge := range purgers {
+			purged[name] = purge(match)
+		}
+	}
+
+	log.Printf("purged cache entries with prefix %q: %v", prefix, purged)
+	writeAdminJSON(w, http.StatusOK, map[string]any{"purged": purged})
+}
+
+// AdminCacheRefreshHandler refetches the series behind the indicator named
+// by the {tool} path value, or the series listed in the comma-separated
+// series query param, replacing every cached copy of them in the series and
+// custom caches
+func (h *ChartHandlers) AdminCacheRefreshHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var ids []string
+	var requests []fred.SeriesRequest
+	if tool := r.PathValue("tool"); tool != "" {
+		ind, ok := h.indicator(tool)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		// Refresh the default inputs even if they aren't cached yet
+		requests = inputRequests(ind.Inputs())
+		for _, req := range requests {
+			ids = append(ids, req.ID)
+		}
+	} else {
+		ids = strings.FieldsFunc(r.URL.Query().Get("series"), func(c rune) bool {
+			return c == ','
+		})
+		if len(ids) == 0 {
+			http.Error(w, "Missing series", http.StatusBadRequest)
+			return
+		}
+	}
+
+	seriesKeys, seriesErr := h.refreshSeries(r.Context(), h.seriesCache, ids, requests...)
+	customKeys, customErr := h.refreshSeries(r.Context(), h.customCache, ids)
+	if err := errors.Join(seriesErr, customErr); err != nil {
+		log.Print("failed to refresh series:", err)
+		status, message := chartDataError(err)
+		writeAdminJSON(w, status, map[string]string{"error": message, "detail": err.Error()})
+		return
+	}
+
+	writeAdminJSON(w, http.StatusOK, map[string]any{
+		"refreshed": map[string][]string{
+			"series": seriesKeys,
+			"custom": customKeys,
+		},
+	})
+}
+
+// writeAdminJSON writes v as the JSON body of an admin response
+func writeAdminJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Print("failed to write response:", err)
+	}
+}
@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"encoding/json"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+// serve serves a request through the routes of h
+func serve(h *ChartHandlers, method, target string) *httptest.ResponseRecorder {
+	mux := http.NewServeMux()
+	for _, r := range h.Routes() {
+		mux.HandleFunc(r.Pattern, r.Handler)
+	}
+	mux.HandleFunc("/{tool}/sources", h.SourcesHandler)
+	mux.HandleFunc("/admin/cache", h.AdminCacheHandler)
+	mux.HandleFunc("/admin/cache/purge", h.AdminCachePurgeHandler)
+	mux.HandleFunc("/admin/cache/refresh", h.AdminCacheRefreshHandler)
+	mux.HandleFunc("/admin/cache/refresh/{tool}", h.AdminCacheRefreshHandler)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(method, target, nil))
+	return rec
+}
+
+func TestAdminCacheRefresh(t *testing.T) {
+	srv := newTestServer(t)
+	h := newTestHandlers(t, srv)
+
+	// Cache two variants of the yield curve, and recession shading
+	for _, target := range []string{
+		"/yield-curve/chart?frequency=w",
+		"/yield-curve/chart?spread=10y3m&frequency=m",
+		"/real-interest-rate/chart?recessions=on",
+	} {
+		if rec := serve(h, http.MethodGet, target); rec.Code != http.StatusOK {
+			t.Fatalf("GET %s = %d", target, rec.Code)
+		}
+	}
+
+	tests := []struct {
+		target string
+		want   []string
+	}{
+		{
+			// Every cached variant is refreshed, along with the default
+			// inputs
+			target: "/admin/cache/refresh/yield-curve",
+			want: []string{
+				"series:T10Y2Y:m:avg:lin:true:latest",
+				"series:T10Y2Y:w:avg:lin:true:latest",
+				"series:T10Y3M:m:avg:lin:true:latest",
+			},
+		},
+		{
+			target: "/admin/cache/refresh?series=USREC",
+			want:   []string{"series:USREC:m::lin:false:latest"},
+		},
+	}
+	for _, tt := range tests {
+		rec := serve(h, http.MethodPost, tt.target)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("POST %s = %d: %s", tt.target, rec.Code, rec.Body)
+		}
+
+		var body struct {
+			Refreshed map[string][]string `json:"refreshed"`
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		if got := body.Refreshed["series"]; !slices.Equal(got, tt.want) {
+			t.Errorf("POST %s refreshed %v, want %v", tt.target, got, tt.want)
+		}
+	}
+}
+
+func TestAdminCacheRefreshErrors(t *testing.T) {
+	h := newTestHandlers(t, newTestServer(t))
+
+	tests := []struct {
+		method string
+		target string
+		want   int
+	}{
+		{http.MethodGet, "/admin/cache/refresh/msindex", http.StatusMethodNotAllowed},
+		{http.MethodPost, "/admin/cache/refresh/unknown", http.StatusNotFound},
+		{http.MethodPost, "/admin/cache/refresh", http.StatusBadRequest},
+		{http.MethodPost, "/admin/cache/refresh?series=NOPE", http.StatusOK},
+	}
+	for _, tt := range tests {
+		if rec := serve(h, tt.method, tt.target); rec.Code != tt.want {
+			t.Errorf("%s %s = %d, want %d", tt.method, tt.target, rec.Code, tt.want)
+		}
+	}
+}
+
+func TestAdminCachePurge(t *testing.T) {
+	h := newTestHandlers(t, newTestServer(t))
+
+	if rec := serve(h, http.MethodGet, "/real-interest-rate/chart"); rec.Code != http.StatusOK {
+		t.Fatalf("GET chart = %d", rec.Code)
+	}
+
+	rec := serve(h, http.MethodPost, "/admin/cache/purge?prefix=series:TB3MS&cache=series")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("POST purge = %d: %s", rec.Code, rec.Body)
+	}
+	for _, e := range h.seriesCache.Entries() {
+		if e.Key == "series:TB3MS:m:avg:lin:true:latest" {
+			t.Error("purged entry is still cached")
+		}
+	}
+	if h.seriesCache.Len() != 1 {
+		t.Errorf("series cache has %d entries, want CPIAUCNS left", h.seriesCache.Len())
+	}
+
+	if rec := serve(h, http.MethodPost, "/admin/cache/purge?prefix=x&cache=nope"); rec.Code != http.StatusBadRequest {
+		t.Errorf("POST purge of an unknown cache = %d, want 400", rec.Code)
+	}
+
+	// Release calendars are purged by series like the series themselves
+	rec = serve(h, http.MethodPost, "/admin/cache/purge?prefix=release:TB3MS&cache=release")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("POST purge = %d: %s", rec.Code, rec.Body)
+	}
+	if keys := cacheKeys(t, h)["release"]; !slices.Equal(keys, []string{"release:CPIAUCNS"}) {
+		t.Errorf("release cache has %v, want CPIAUCNS left", keys)
+	}
+
+	// An empty prefix purges every cache
+	rec = serve(h, http.MethodPost, "/admin/cache/purge?prefix=")
+	var body struct {
+		Purged map[string]int `json:"purged"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("POST purge = %d, %v", rec.Code, err)
+	}
+	want := map[string]int{"series": 1, "custom": 0, "sources": 0, "release": 1}
+	if !maps.Equal(body.Purged, want) {
+		t.Errorf("POST purge purged %v, want %v", body.Purged, want)
+	}
+	for name, keys := range cacheKeys(t, h) {
+		if len(keys) > 0 {
+			t.Errorf("%s cache has %v after purging everything", name, keys)
+		}
+	}
+}
+
+// cacheKeys returns the keys of each cache in the admin listing, sorted
+func cacheKeys(t *testing.T, h *ChartHandlers) map[string][]string {
+	t.Helper()
+
+	rec := serve(h, http.MethodGet, "/admin/cache")
+	var listing map[string]adminCache
+	if err := json.NewDecoder(rec.Body).Decode(&listing); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("GET /admin/cache = %d, %v", rec.Code, err)
+	}
+
+	keys := make(map[string][]string)
+	for name, c := range listing {
+		keys[name] = []string{}
+		for _, e := range c.Entries {
+			keys[name] = append(keys[name], e.Key)
+		}
+		slices.Sort(keys[name])
+	}
+	return keys
+}
+
+func TestAdminCacheList(t *testing.T) {
+	h := newTestHandlers(t, newTestServer(t))
+
+	if rec := serve(h, http.MethodGet, "/real-interest-rate/chart"); rec.Code != http.StatusOK {
+		t.Fatalf("GET chart = %d", rec.Code)
+	}
+	if rec := serve(h, http.MethodGet, "/real-interest-rate/sources"); rec.Code != http.StatusOK {
+		t.Fatalf("GET sources = %d", rec.Code)
+	}
+
+	want := map[string][]string{
+		"series":  {"series:CPIAUCNS:m:avg:lin:true:latest", "series:TB3MS:m:avg:lin:true:latest"},
+		"custom":  {},
+		"sources": {"sources:CPIAUCNS", "sources:TB3MS"},
+		"release": {"release:CPIAUCNS", "release:TB3MS"},
+	}
+	got := cacheKeys(t, h)
+	if !maps.EqualFunc(got, want, slices.Equal) {
+		t.Errorf("GET /admin/cache keys = %v, want %v", got, want)
+	}
+
+	if rec := serve(h, http.MethodPost, "/admin/cache"); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST /admin/cache = %d, want 405", rec.Code)
+	}
+}
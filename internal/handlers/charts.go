@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"io/fs"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/shanehull/shanehull.com/internal/cache"
+	"github.com/shanehull/shanehull.com/internal/fred"
+	"github.com/shanehull/shanehull.com/internal/templates"
+)
+
+// ChartHandlers serves the FRED-backed chart tools.
+type ChartHandlers struct {
+	fred *fred.Client
+
+	seriesCache  *seriesCache
+	customCache  *seriesCache
+	sourcesCache *cache.Cache[string, *fred.SeriesInfo]
+	releaseCache *cache.Cache[string, []time.Time]
+
+	// resultCache holds custom formula results in memory only, since they
+	// are tied to the cached series they were computed from
+	resultCache *cache.Cache[string, *customResult]
+
+	indicators map[string]Indicator
+	order      []string
+
+	// customSeries are the series custom formulas can use, or nil for any
+	customSeries []string
+}
+
+// NewChartHandlers returns chart handlers that fetch data with the given
+// FRED client, with the built-in indicators registered. cacheOpts are
+// applied to every cache the handlers create, after the defaults. Call Close
+// when the handlers are no longer needed.
+func NewChartHandlers(client *fred.Client, cacheOpts ...cache.Option) *ChartHandlers {
+	cacheOpts = append([]cache.Option{cache.WithStaleGrace(staleGrace)}, cacheOpts...)
+	sourcesOpts := append([]cache.Option{cache.WithMaxEntries(sourcesCacheEntries)}, cacheOpts...)
+
+	h := &ChartHandlers{
+		fred:         client,
+		seriesCache:  newSeriesCache("series", seriesCacheEntries, seriesCacheBytes, cacheOpts...),
+		customCache:  newSeriesCache("custom", customCacheEntries, customCacheBytes, cacheOpts...),
+		sourcesCache: cache.NewSized[string](0, seriesInfoSize, sourcesOpts...),
+		releaseCache: cache.New[string, []time.Time](append([]cache.Option{cache.WithMaxEntries(releaseCacheEntries)}, cacheOpts...)...),
+		resultCache:  cache.NewSized[string](customCacheBytes, customResultSize, cache.WithMaxEntries(customCacheEntries)),
+		indicators:   make(map[string]Indicator),
+	}
+	defs, err := fs.Sub(builtinDefinitions, "indicators")
+	if err != nil {
+		panic(err)
+	}
+	builtins, err := loadDefinitions(defs)
+	if err != nil {
+		panic(err)
+	}
+	for _, ind := range append(slices.Clone(builtinIndicators), builtins...) {
+		if err := h.Register(ind); err != nil {
+			panic(err)
+		}
+	}
+	return h
+}
+
+// Close stops the background work of the handlers' caches
+func (h *ChartHandlers) Close() {
+	h.seriesCache.Close()
+	h.customCache.Close()
+	h.sourcesCache.Close()
+	h.releaseCache.Close()
+	h.resultCache.Close()
+}
+
+// CacheVersion identifies the format of the values the chart handlers
+// cache. Bump it whenever a cached type changes so that entries persisted
+// by an earlier build are not decoded into the new one.
+const CacheVersion = "2"
+
+// staleGrace is how long expired series and series metadata are kept to
+// serve while they are refreshed, or while FRED is unavailable
+const staleGrace = 7 * 24 * time.Hour
+
+// chartResult is the computed data for a chart along with the fetch reports
+// of the series it was built from
+type chartResult struct {
+	Data    []templates.LineChartData
+	Reports []fred.FetchReport
+
+	// Bands are the periods to shade on the chart
+	Bands []templates.ChartBand
+
+	// UpdatedAt is when the input series were fetched, and RefreshErr is
+	// set when stale series are served because refreshing them failed
+	UpdatedAt  time.Time
+	RefreshErr error
+}
+
+// setDataAsOf adds a "data as of" notice to the chart options when stale
+// data is being served because refreshing it failed
+func setDataAsOf(options map[string]string, result *chartResult) {
+	if result.RefreshErr != nil {
+		log.Print("serving stale chart data:", result.RefreshErr)
+		options["dataAsOf"] = result.UpdatedAt.UTC().Format("2006-01-02 15:04 MST")
+	}
+}
@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/shanehull/shanehull.com/internal/formula"
+	"github.com/shanehull/shanehull.com/internal/fred"
+)
+
+// customSlug is the path segment of the custom formula tool, which is
+// reserved so no indicator can be registered under it
+const customSlug = "custom"
+
+// Limits on custom formulas, which come straight from the URL, so that one
+// request can't fetch or compute an unbounded amount
+const (
+	maxFormulaLength   = 200
+	maxFormulaSize     = 50
+	maxFormulaSeries   = 5
+	maxFormulaLookback = 1000
+)
+
+// customResultTTL is how long custom formula results are kept in memory.
+// They are only reused while their series are unchanged.
+const customResultTTL = time.Hour
+
+// customParams are the query params of a custom chart besides the range,
+// overlay and vintage
+var customParams = []string{"formula", "frequency"}
+
+// customFrequencies are the frequencies custom charts can be drawn at
+var customFrequencies = []fred.Frequency{
+	fred.FrequencyDaily,
+	fred.FrequencyWeekly,
+	fred.FrequencyMonthly,
+	fred.FrequencyQuarterly,
+	fred.FrequencyAnnual,
+}
+
+var seriesIDPattern = regexp.MustCompile(`^[A-Z][A-Z0-9_]{0,29}$`)
+
+// AllowCustomSeries limits the series custom formulas can use to ids. By
+// default any FRED series can be used.
+func (h *ChartHandlers) AllowCustomSeries(ids ...string) {
+	h.customSeries = slices.Clone(ids)
+}
+
+// customIndicator builds an indicator from the formula and frequency query
+// params of a custom chart request. The series are aggregated to the
+// frequency, monthly by default, by averaging.
+func (h *ChartHandlers) customIndicator(r *http.Request) (Indicator, error) {
+	query := r.URL.Query()
+
+	src := query.Get("formula")
+	if src == "" {
+		return nil, errors.New("enter a formula, e.g. DGS10 - yoy(CPIAUCSL)")
+	}
+	if len(src) > maxFormulaLength {
+		return nil, fmt.Errorf("formula is too long, the limit is %d characters", maxFormulaLength)
+	}
+
+	expr, err := formula.Parse(src)
+	if err != nil {
+		return nil, err
+	}
+	if expr.Size() > maxFormulaSize {
+		return nil, fmt.Errorf("formula is too complex, the limit is %d operations", maxFormulaSize)
+	}
+	if expr.Lookback() > maxFormulaLookback {
+		return nil, fmt.Errorf("formula's lags and windows are too long, the limit is %d periods in total", maxFormulaLookback)
+	}
+
+	ids := expr.Series()
+	if len(ids) > maxFormulaSeries {
+		return nil, fmt.Errorf("formula uses %d series, the limit is %d", len(ids), maxFormulaSeries)
+	}
+	for _, id := range ids {
+		if !seriesIDPattern.MatchString(id) {
+			return nil, fmt.Errorf("invalid series ID %q, FRED IDs are uppercase, e.g. CPIAUCSL", id)
+		}
+		if h.customSeries != nil && !slices.Contains(h.customSeries, id) {
+			return nil, fmt.Errorf("series %s is not available for custom charts", id)
+		}
+	}
+
+	freq := fred.FrequencyMonthly
+	if f := query.Get("frequency"); f != "" {
+		freq = fred.Frequency(f)
+		if !slices.Contains(customFrequencies, freq) {
+			return nil, fmt.Errorf("invalid frequency %q", f)
+		}
+	}
+
+	// FRED only aggregates to frequencies lower than daily
+	agg := fred.AggregationAverage
+	if freq == fred.FrequencyDaily {
+		agg = ""
+	}
+
+	inputs := make([]Input, len(ids))
+	for i, id := range ids {
+		inputs[i] = Input{
+			Role:     "Input",
+			SeriesID: id,
+			Options: &fred.FetchOptions{
+				Frequency:         freq,
+				AggregationMethod: agg,
+				Units:             fred.UnitsLevels,
+				KeepMissing:       true,
+			},
+		}
+	}
+
+	title := strings.Join(strings.Fields(expr.String()), " ")
+	return &customFormula{
+		key: fmt.Sprintf("%s:%s", freq, title),
+		indicator: &indicator{
+			slug:   customSlug,
+			inputs: inputs,
+			labels: Labels{
+				// Formulas may span lines, but titles are one line
+				Title:  title,
+				Column: "value",
+			},
+			overlay: OverlayAverage,
+			compute: func(series map[string][]fred.DataPoint, _ *time.Time) ([]fred.DataPoint, error) {
+				return expr.Eval(series)
+			},
+		},
+	}, nil
+}
+
+// customFormula is the indicator of a custom chart. Its results don't
+// depend on the range, so they are cached under key, which identifies the
+// formula and the options its series are fetched with.
+type customFormula struct {
+	*indicator
+	key string
+}
+
+// customResult is a cached custom formula result and the series it was
+// computed from
+type customResult struct {
+	series map[string]*fred.Series
+	points []fred.DataPoint
+}
+
+// computedFrom reports whether the result was computed from the series in
+// set. Cached series are replaced rather than changed, so the same pointers
+// mean the same data.
+func (r *customResult) computedFrom(set *seriesSet) bool {
+	if len(r.series) != len(set.Series) {
+		return false
+	}
+	for id, s := range set.Series {
+		if r.series[id] != s {
+			return false
+		}
+	}
+	return true
+}
+
+// customResultSize estimates the memory used by a cached result
+func customResultSize(r *customResult) int64 {
+	return int64(len(r.points)) * dataPointSize
+}
+
+// compute computes ind from the series in set. Custom formula results are
+// cached and reused until any of their series is refetched.
+func (h *ChartHandlers) compute(ind Indicator, set *seriesSet, start, vintage *time.Time) ([]fred.DataPoint, error) {
+	series := make(map[string][]fred.DataPoint, len(set.Series))
+	for id, s := range set.Series {
+		series[id] = s.Points
+	}
+
+	custom, ok := ind.(*customFormula)
+	if !ok {
+		return ind.Compute(series, start)
+	}
+
+	key := custom.key + ":" + vintageKey(vintage)
+	if result, ok := h.resultCache.Get(key); ok && result.computedFrom(set) {
+		return result.points, nil
+	}
+	points, err := ind.Compute(series, start)
+	if err != nil {
+		return nil, err
+	}
+	h.resultCache.Set(key, &customResult{series: set.Series, points: points}, customResultTTL)
+	return points, nil
+}
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// customTarget returns the path of a custom chart route for a formula
+func customTarget(route, formula string, params ...string) string {
+	query := url.Values{"formula": {formula}}
+	for i := 0; i+1 < len(params); i += 2 {
+		query.Set(params[i], params[i+1])
+	}
+	return "/custom/" + route + "?" + query.Encode()
+}
+
+func TestCustomFrequencies(t *testing.T) {
+	h := newTestHandlers(t, newTestServer(t))
+
+	for _, freq := range customFrequencies {
+		t.Run(string(freq), func(t *testing.T) {
+			rec := serve(h, http.MethodGet, customTarget("chart", "T10Y2Y - T10Y3M", "frequency", string(freq)))
+			if strings.Contains(rec.Body.String(), "chart-error") {
+				t.Fatalf("chart error: %s", rec.Body)
+			}
+			if config := parseChart(t, rec.Body.String()); len(config.Labels) == 0 {
+				t.Error("chart has no data")
+			}
+
+			for _, route := range []string{"data", "data.csv"} {
+				if rec := serve(h, http.MethodGet, customTarget(route, "T10Y2Y - T10Y3M", "frequency", string(freq))); rec.Code != http.StatusOK {
+					t.Errorf("GET %s = %d: %s", route, rec.Code, rec.Body)
+				}
+			}
+		})
+	}
+}
+
+func TestCustomLabel(t *testing.T) {
+	h := newTestHandlers(t, newTestServer(t))
+
+	rec := serve(h, http.MethodGet, customTarget("chart", "\tT10Y2Y\n  -\r\nT10Y3M "))
+	config := parseChart(t, rec.Body.String())
+	if got, want := config.Datasets[0].Label, "T10Y2Y - T10Y3M"; got != want {
+		t.Errorf("label = %q, want %q", got, want)
+	}
+}
+
+func TestCustomCache(t *testing.T) {
+	h := newTestHandlers(t, newTestServer(t))
+
+	if rec := serve(h, http.MethodGet, customTarget("chart", "GDP / TB3MS", "frequency", "q")); strings.Contains(rec.Body.String(), "chart-error") {
+		t.Fatalf("chart error: %s", rec.Body)
+	}
+
+	// Custom charts don't share the series cache with the indicators
+	if n := h.seriesCache.Len(); n != 0 {
+		t.Errorf("series cache has %d entries, want 0", n)
+	}
+	if n := h.customCache.Len(); n != 2 {
+		t.Errorf("custom cache has %d entries, want 2", n)
+	}
+	for _, e := range h.customCache.Entries() {
+		if !strings.HasPrefix(e.Key, "custom:") {
+			t.Errorf("custom cache key %q doesn't start with custom:", e.Key)
+		}
+	}
+}
+
+func TestCustomErrors(t *testing.T) {
+	h := newTestHandlers(t, newTestServer(t))
+	h.AllowCustomSeries("GDP", "TB3MS", "CPIAUCNS", "T10Y2Y", "T10Y3M", "USREC")
+
+	tests := []struct {
+		name    string
+		formula string
+		params  []string
+		want    string
+	}{
+		{"empty", "", nil, "enter a formula"},
+		{"too long", strings.Repeat("GDP+", 50) + "GDP", nil, "formula is too long"},
+		{"too complex", strings.Repeat("-", 60) + "GDP", nil, "formula is too complex"},
+		{"windows too long", "std(std(GDP, 999), 999)", nil, "the limit is 1000 periods in total"},
+		{"too many series", "GDP + TB3MS + CPIAUCNS + T10Y2Y + T10Y3M + USREC", nil, "formula uses 6 series, the limit is 5"},
+		{"syntax error", "GDP +", nil, "unexpected end of formula at position 6"},
+		{"lowercase series", "gdp", nil, `invalid series ID &#34;gdp&#34;`},
+		{"series not allowed", "DGS10", nil, "series DGS10 is not available for custom charts"},
+		{"invalid frequency", "GDP", []string{"frequency", "x"}, `invalid frequency &#34;x&#34;`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(h, http.MethodGet, customTarget("chart", tt.formula, tt.params...))
+			if body := rec.Body.String(); !strings.Contains(body, "chart-error") || !strings.Contains(body, tt.want) {
+				t.Errorf("chart = %s, want an error containing %q", body, tt.want)
+			}
+
+			if rec := serve(h, http.MethodGet, customTarget("data", tt.formula, tt.params...)); rec.Code != http.StatusBadRequest {
+				t.Errorf("GET data = %d, want 400", rec.Code)
+			}
+		})
+	}
+}
+
+func TestCustomResultCached(t *testing.T) {
+	h := newTestHandlers(t, newTestServer(t))
+
+	// The same formula over different ranges, written differently, is
+	// computed once
+	for _, target := range []string{
+		customTarget("chart", "T10Y2Y - T10Y3M"),
+		customTarget("chart", " T10Y2Y  -\nT10Y3M", "range", "5y"),
+		customTarget("data", "T10Y2Y - T10Y3M", "range", "10y"),
+	} {
+		if rec := serve(h, http.MethodGet, target); rec.Code != http.StatusOK || strings.Contains(rec.Body.String(), "chart-error") {
+			t.Fatalf("GET %s = %d: %.200s", target, rec.Code, rec.Body)
+		}
+	}
+	if n := h.resultCache.Len(); n != 1 {
+		t.Fatalf("result cache has %d entries, want 1", n)
+	}
+	if stats := h.resultCache.Stats(); stats.Hits != 2 {
+		t.Errorf("result cache hits = %d, want 2", stats.Hits)
+	}
+
+	// Refreshing a series recomputes the result from the new copy
+	if rec := serve(h, http.MethodPost, "/admin/cache/refresh?series=T10Y2Y"); rec.Code != http.StatusOK {
+		t.Fatalf("POST refresh = %d: %s", rec.Code, rec.Body)
+	}
+	serve(h, http.MethodGet, customTarget("chart", "T10Y2Y - T10Y3M"))
+
+	key := h.resultCache.Entries()[0].Key
+	result, _ := h.resultCache.Get(key)
+	for _, e := range h.customCache.Entries() {
+		if s, _ := h.customCache.Get(e.Key); result.series[s.ID] != s {
+			t.Errorf("result was not recomputed from the refreshed %s", s.ID)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-yaml"
+
+	"github.com/shanehull/shanehull.com/internal/formula"
+	"github.com/shanehull/shanehull.com/internal/fred"
+)
+
+// builtinDefinitions are the indicators defined in YAML that every
+// ChartHandlers serves
+//
+//go:embed indicators/*.yaml
+var builtinDefinitions embed.FS
+
+// definition is an indicator defined in YAML, computed by evaluating a
+// formula over its series
+type definition struct {
+	Slug    string  `yaml:"slug"`
+	Title   string  `yaml:"title"`
+	YAxis   string  `yaml:"y_axis"`
+	Column  string  `yaml:"column"`
+	Overlay Overlay `yaml:"overlay"`
+
+	// Frequency and Aggregation apply to every series that doesn't set its
+	// own
+	Frequency   fred.Frequency         `yaml:"frequency"`
+	Aggregation fred.AggregationMethod `yaml:"aggregation"`
+
+	Series  []definitionSeries `yaml:"series"`
+	Formula string             `yaml:"formula"`
+}
+
+// definitionSeries is a series used by a YAML indicator
+type definitionSeries struct {
+	ID          string                 `yaml:"id"`
+	Role        string                 `yaml:"role"`
+	Frequency   fred.Frequency         `yaml:"frequency"`
+	Aggregation fred.AggregationMethod `yaml:"aggregation"`
+	Units       fred.Units             `yaml:"units"`
+}
+
+// parseDefinition parses and validates a YAML indicator definition
+func parseDefinition(data []byte) (Indicator, error) {
+	var def definition
+	if err := yaml.UnmarshalWithOptions(data, &def, yaml.Strict()); err != nil {
+		return nil, fmt.Errorf("failed to parse definition: %w", err)
+	}
+
+	if def.Title == "" {
+		return nil, fmt.Errorf("indicator %q has no title", def.Slug)
+	}
+	if def.Column == "" {
+		def.Column = strings.ReplaceAll(def.Slug, "-", "_")
+	}
+
+	expr, err := formula.Parse(def.Formula)
+	if err != nil {
+		return nil, err
+	}
+
+	inputs := make([]Input, len(def.Series))
+	ids := make([]string, len(def.Series))
+	for i, s := range def.Series {
+		opts, err := s.options(def.Frequency, def.Aggregation)
+		if err != nil {
+			return nil, err
+		}
+		inputs[i] = Input{Role: s.Role, SeriesID: s.ID, Options: opts}
+		ids[i] = s.ID
+	}
+	for _, id := range expr.Series() {
+		if !slices.Contains(ids, id) {
+			return nil, fmt.Errorf("formula uses series %s, which is not listed in series", id)
+		}
+	}
+
+	return &indicator{
+		slug:   def.Slug,
+		inputs: inputs,
+		labels: Labels{
+			Title:  def.Title,
+			YAxis:  def.YAxis,
+			Column: def.Column,
+		},
+		overlay: def.Overlay,
+		compute: func(series map[string][]fred.DataPoint, _ *time.Time) ([]fred.DataPoint, error) {
+			return expr.Eval(series)
+		},
+	}, nil
+}
+
+// options returns the fetch options for the series, falling back to the
+// indicator's frequency and aggregation
+func (s definitionSeries) options(freq fred.Frequency, agg fred.AggregationMethod) (*fred.FetchOptions, error) {
+	if s.ID == "" {
+		return nil, fmt.Errorf("series has no id")
+	}
+
+	opts := &fred.FetchOptions{
+		Frequency:         freq,
+		AggregationMethod: agg,
+		Units:             fred.UnitsLevels,
+		KeepMissing:       true,
+	}
+	if s.Frequency != "" {
+		opts.Frequency = s.Frequency
+	}
+	if s.Aggregation != "" {
+		opts.AggregationMethod = s.Aggregation
+	}
+	if s.Units != "" {
+		opts.Units = s.Units
+	}
+
+	// Check the options as the client will when fetching, so that a bad
+	// definition fails to load rather than failing every request
+	if err := opts.Validate(); err != nil {
+		return nil, fmt.Errorf("series %s has invalid options: %w", s.ID, err)
+	}
+
+	return opts, nil
+}
+
+// loadDefinitions parses every .yaml and .yml file in fsys, in file name
+// order
+func loadDefinitions(fsys fs.FS) ([]Indicator, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read indicators: %w", err)
+	}
+
+	var inds []Indicator
+	for _, entry := range entries {
+		ext := path.Ext(entry.Name())
+		if entry.IsDir() || (ext != ".yaml" && ext != ".yml") {
+			continue
+		}
+
+		data, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read indicator %s: %w", entry.Name(), err)
+		}
+		ind, err := parseDefinition(data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid indicator %s: %w", entry.Name(), err)
+		}
+		inds = append(inds, ind)
+	}
+
+	return inds, nil
+}
+
+// LoadIndicators registers the indicators defined in the .yaml and .yml
+// files in dir
+func (h *ChartHandlers) LoadIndicators(dir string) error {
+	inds, err := loadDefinitions(os.DirFS(dir))
+	if err != nil {
+		return err
+	}
+
+	for _, ind := range inds {
+		if err := h.Register(ind); err != nil {
+			return err
+		}
+	}
+	return nil
+}
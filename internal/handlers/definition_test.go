@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/shanehull/shanehull.com/internal/fred"
+)
+
+const testDefinition = `
+slug: equity-share
+title: Equity Share
+y_axis: Ratio
+frequency: q
+aggregation: avg
+series:
+  - id: GDP
+    role: Output
+  - id: TB3MS
+    role: Rate
+    frequency: m
+    units: pch
+formula: GDP / TB3MS
+`
+
+func TestParseDefinition(t *testing.T) {
+	ind, err := parseDefinition([]byte(testDefinition))
+	if err != nil {
+		t.Fatalf("parseDefinition() error = %v", err)
+	}
+
+	if ind.Slug() != "equity-share" {
+		t.Errorf("Slug() = %q, want equity-share", ind.Slug())
+	}
+	// The column defaults to the slug
+	want := Labels{Title: "Equity Share", YAxis: "Ratio", Column: "equity_share"}
+	if ind.Labels() != want {
+		t.Errorf("Labels() = %+v, want %+v", ind.Labels(), want)
+	}
+
+	// Series fall back to the indicator's frequency and aggregation
+	inputs := ind.Inputs()
+	if len(inputs) != 2 {
+		t.Fatalf("Inputs() has %d inputs, want 2", len(inputs))
+	}
+	wantOpts := []fred.FetchOptions{
+		{Frequency: fred.FrequencyQuarterly, AggregationMethod: fred.AggregationAverage, Units: fred.UnitsLevels, KeepMissing: true},
+		{Frequency: fred.FrequencyMonthly, AggregationMethod: fred.AggregationAverage, Units: fred.UnitsPercentChange, KeepMissing: true},
+	}
+	for i, in := range inputs {
+		if !reflect.DeepEqual(*in.Options, wantOpts[i]) {
+			t.Errorf("input %s options = %+v, want %+v", in.SeriesID, *in.Options, wantOpts[i])
+		}
+	}
+}
+
+func TestParseDefinitionErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		want string
+	}{
+		{"unknown field", testDefinition + "colour: red\n", "unknown field"},
+		{"misspelled series field", strings.Replace(testDefinition, "role: Output", "rol: Output", 1), "unknown field"},
+		{"no title", strings.Replace(testDefinition, "title: Equity Share", "", 1), "has no title"},
+		{"syntax error", strings.Replace(testDefinition, "GDP / TB3MS", "GDP /", 1), "unexpected end of formula"},
+		{"constant formula", strings.Replace(testDefinition, "GDP / TB3MS", "1 + 2", 1), "no series referenced"},
+		{"unknown series", strings.Replace(testDefinition, "GDP / TB3MS", "GDP / DGS10", 1), "formula uses series DGS10"},
+		{"series without id", strings.Replace(testDefinition, "id: GDP", "id: ''", 1), "series has no id"},
+		{"invalid frequency", strings.Replace(testDefinition, "frequency: m", "frequency: x", 1), `unknown frequency "x"`},
+		{"invalid aggregation", strings.Replace(testDefinition, "aggregation: avg", "aggregation: median", 1), `unknown aggregation method "median"`},
+		{"invalid units", strings.Replace(testDefinition, "units: pch", "units: percent", 1), `unknown units "percent"`},
+		{"aggregation without frequency", strings.Replace(testDefinition, "frequency: q\n", "", 1), `aggregation method "avg" requires a frequency`},
+		{"aggregation at daily frequency", strings.Replace(testDefinition, "frequency: q", "frequency: d", 1), `aggregation method "avg" has no effect at daily frequency`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseDefinition([]byte(tt.yaml))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("parseDefinition() error = %v, want one containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadDefinitions(t *testing.T) {
+	fsys := fstest.MapFS{
+		"b.yml":      {Data: []byte(strings.Replace(testDefinition, "equity-share", "b", 1))},
+		"a.yaml":     {Data: []byte(strings.Replace(testDefinition, "equity-share", "a", 1))},
+		"README.md":  {Data: []byte("not an indicator")},
+		"old/c.yaml": {Data: []byte("not: loaded")},
+	}
+
+	inds, err := loadDefinitions(fsys)
+	if err != nil {
+		t.Fatalf("loadDefinitions() error = %v", err)
+	}
+	var slugs []string
+	for _, ind := range inds {
+		slugs = append(slugs, ind.Slug())
+	}
+	if want := []string{"a", "b"}; !slices.Equal(slugs, want) {
+		t.Errorf("loaded %v, want %v", slugs, want)
+	}
+
+	// Errors name the file
+	fsys["c.yaml"] = &fstest.MapFile{Data: []byte("slug: c\n")}
+	if _, err := loadDefinitions(fsys); err == nil || !strings.Contains(err.Error(), "c.yaml") {
+		t.Errorf("loadDefinitions() error = %v, want one naming c.yaml", err)
+	}
+}
+
+func TestLoadIndicators(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{
+			name:  "valid",
+			files: map[string]string{"equity-share.yaml": testDefinition},
+		},
+		{
+			name:  "uppercase slug",
+			files: map[string]string{"a.yaml": strings.Replace(testDefinition, "equity-share", "Equity-Share", 1)},
+			want:  "invalid indicator slug",
+		},
+		{
+			name:  "slug with spaces",
+			files: map[string]string{"a.yaml": strings.Replace(testDefinition, "equity-share", "'equity share'", 1)},
+			want:  "invalid indicator slug",
+		},
+		{
+			name:  "reserved slug",
+			files: map[string]string{"a.yaml": strings.Replace(testDefinition, "equity-share", customSlug, 1)},
+			want:  "invalid indicator slug",
+		},
+		{
+			name:  "no inputs",
+			files: map[string]string{"a.yaml": "slug: a\ntitle: A\nformula: 1\n"},
+			want:  "no series referenced",
+		},
+		{
+			name:  "aggregation at daily frequency",
+			files: map[string]string{"a.yaml": strings.Replace(testDefinition, "frequency: q", "frequency: d", 1)},
+			want:  "has no effect at daily frequency",
+		},
+		{
+			name:  "duplicate of a built-in",
+			files: map[string]string{"a.yaml": strings.Replace(testDefinition, "equity-share", "real-interest-rate", 1)},
+			want:  `"real-interest-rate" is already registered`,
+		},
+		{
+			name: "duplicate in the directory",
+			files: map[string]string{
+				"a.yaml": testDefinition,
+				"b.yaml": testDefinition,
+			},
+			want: `"equity-share" is already registered`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, data := range tt.files {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			h := newTestHandlers(t, newTestServer(t))
+			err := h.LoadIndicators(dir)
+			if tt.want == "" {
+				if err != nil {
+					t.Fatalf("LoadIndicators() error = %v", err)
+				}
+				if _, ok := h.indicator("equity-share"); !ok {
+					t.Error("indicator not registered")
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("LoadIndicators() error = %v, want one containing %q", err, tt.want)
+			}
+		})
+	}
+}
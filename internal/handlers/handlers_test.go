@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"encoding/json"
+	"html"
+	"math"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/shanehull/shanehull.com/internal/fred"
+	"github.com/shanehull/shanehull.com/internal/fred/fredtest"
+)
+
+// testStart is the date the fake series start on
+var testStart = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// newTestServer returns a fake FRED server with every series the built-in
+// indicators and recession shading use
+func newTestServer(t *testing.T) *fredtest.Server {
+	t.Helper()
+
+	srv := fredtest.NewServer()
+	t.Cleanup(srv.Close)
+
+	quarters := (time.Now().Year() - testStart.Year()) * 4
+	for _, id := range []string{equityID, networthID, "NCBEILQ027S", "GDP"} {
+		srv.AddSeries(id, fred.FrequencyQuarterly, fredtest.Points(fred.FrequencyQuarterly, testStart, trend(quarters, 100)...))
+	}
+
+	months := quarters * 3
+	srv.AddSeries("TB3MS", fred.FrequencyMonthly, fredtest.Points(fred.FrequencyMonthly, testStart, trend(months, 3)...))
+	srv.AddSeries("CPIAUCNS", fred.FrequencyMonthly, fredtest.Points(fred.FrequencyMonthly, testStart, trend(months, 200)...))
+
+	// The recession indicators mark 2008 as a recession
+	usrec := make([]float64, months)
+	for i := 96; i < 108; i++ {
+		usrec[i] = 1
+	}
+	srv.AddSeries(recessionMonthlyID, fred.FrequencyMonthly, fredtest.Points(fred.FrequencyMonthly, testStart, usrec...))
+	usrecq := make([]float64, quarters)
+	for i := 32; i < 36; i++ {
+		usrecq[i] = 1
+	}
+	srv.AddSeries(recessionQuarterlyID, fred.FrequencyQuarterly, fredtest.Points(fred.FrequencyQuarterly, testStart, usrecq...))
+
+	// The spreads are inverted for a few months of every year
+	days := int(time.Since(testStart).Hours() / 24)
+	spread := make([]float64, days)
+	for i := range spread {
+		spread[i] = math.Sin(float64(i) * 2 * math.Pi / 365)
+	}
+	srv.AddSeries(spread10y2yID, fred.FrequencyDaily, fredtest.Points(fred.FrequencyDaily, testStart, spread...))
+	srv.AddSeries(spread10y3mID, fred.FrequencyDaily, fredtest.Points(fred.FrequencyDaily, testStart, spread...))
+
+	return srv
+}
+
+// newTestHandlers returns chart handlers backed by srv
+func newTestHandlers(t *testing.T, srv *fredtest.Server) *ChartHandlers {
+	t.Helper()
+
+	h := NewChartHandlers(srv.Client())
+	t.Cleanup(h.Close)
+	return h
+}
+
+// trend returns n values rising steadily from base, with some noise
+func trend(n int, base float64) []float64 {
+	values := make([]float64, n)
+	for i := range values {
+		values[i] = base * (1 + float64(i)/100 + math.Sin(float64(i))/20)
+	}
+	return values
+}
+
+var chartAttrPattern = regexp.MustCompile(`data-chart="([^"]*)"`)
+
+// chartConfig is the decoded data-chart attribute of a rendered chart
+type chartConfig struct {
+	Labels   []string `json:"labels"`
+	Datasets []struct {
+		Label string     `json:"label"`
+		Data  []*float64 `json:"data"`
+	} `json:"datasets"`
+	Bands      []any  `json:"bands"`
+	YAxisLabel string `json:"yAxisLabel"`
+}
+
+// parseChart decodes the chart config from a chart response body
+func parseChart(t *testing.T, body string) chartConfig {
+	t.Helper()
+
+	m := chartAttrPattern.FindStringSubmatch(body)
+	if m == nil {
+		t.Fatalf("no chart in response: %.200s", body)
+	}
+	var config chartConfig
+	if err := json.Unmarshal([]byte(html.UnescapeString(m[1])), &config); err != nil {
+		t.Fatalf("invalid chart config: %v", err)
+	}
+	return config
+}
@@ -1,8 +1,22 @@
 package handlers
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"math"
 	"net/http"
+	"net/url"
 	"sort"
+	"strings"
+	"time"
+
+	"github.com/shanehull/shanehull.com/internal/charts"
+	"github.com/shanehull/shanehull.com/internal/fred"
+	"github.com/shanehull/shanehull.com/internal/templates"
 )
 
 // percentile calculates the percentile value from a sorted slice
@@ -26,14 +40,37 @@ func percentile(sorted []float64, p float64) float64 {
 	return sorted[lower]*(1-weight) + sorted[upper]*weight
 }
 
+// average returns the mean of values, ignoring missing (NaN) values. It
+// returns 0 if there are no values.
+func average(values []float64) float64 {
+	var sum float64
+	var n int
+	for _, v := range values {
+		if math.IsNaN(v) {
+			continue
+		}
+		sum += v
+		n++
+	}
+	if n == 0 {
+		return 0
+	}
+	return sum / float64(n)
+}
+
 // calculateQuartiles returns Q1 and Q3 slices for the given values
 func calculateQuartiles(values []float64) ([]float64, []float64) {
 	if len(values) == 0 {
 		return nil, nil
 	}
 
-	sortedValues := make([]float64, len(values))
-	copy(sortedValues, values)
+	// Missing (NaN) values are excluded
+	sortedValues := make([]float64, 0, len(values))
+	for _, v := range values {
+		if !math.IsNaN(v) {
+			sortedValues = append(sortedValues, v)
+		}
+	}
 	sort.Float64s(sortedValues)
 
 	q1Val := percentile(sortedValues, 0.25)
@@ -52,7 +89,149 @@ func calculateQuartiles(values []float64) ([]float64, []float64) {
 func renderError(w http.ResponseWriter, message string) {
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	html := `<div class="chart-error">
-		<strong>Error:</strong> ` + message + `
+		<strong>Error:</strong> ` + html.EscapeString(message) + `
 	</div>`
 	_, _ = w.Write([]byte(html))
 }
+
+// chartDataError maps an error from loading chart data to an HTTP status
+// code and a message that is safe to show users
+func chartDataError(err error) (int, string) {
+	var fetchErr *fred.FetchError
+	switch {
+	case errors.As(err, &fetchErr) && errors.Is(err, fred.ErrSeriesNotFound):
+		return http.StatusBadGateway, fmt.Sprintf("A data series used by this chart (%s) has been discontinued or is no longer available from FRED.", strings.Join(notFound(fetchErr), ", "))
+	case errors.Is(err, fred.ErrSeriesNotFound):
+		return http.StatusBadGateway, "A data series used by this chart has been discontinued or is no longer available from FRED."
+	case errors.Is(err, fred.ErrRateLimited):
+		return http.StatusServiceUnavailable, "FRED is busy right now. Please try again in a minute."
+	case errors.Is(err, fred.ErrBadAPIKey):
+		return http.StatusInternalServerError, "Chart data is temporarily unavailable."
+	case errors.Is(err, errNoData):
+		return http.StatusNotFound, "No data is available for the selected time range."
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout, "FRED took too long to respond. Please try again later."
+	}
+
+	var apiErr *fred.APIError
+	if errors.As(err, &apiErr) && apiErr.Temporary() {
+		return http.StatusBadGateway, "FRED is unavailable right now. Please try again later."
+	}
+
+	return http.StatusInternalServerError, "Unable to load chart data. Please try again later."
+}
+
+// notFound returns the IDs of the series FRED has no record of
+func notFound(err *fred.FetchError) []string {
+	var ids []string
+	for _, id := range err.Failed() {
+		if errors.Is(err.Errors[id], fred.ErrSeriesNotFound) {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// parseVintage parses the optional vintage query parameter (YYYY-MM-DD),
+// which asks for a chart as it looked on that date. It returns nil if the
+// parameter is not set.
+func parseVintage(r *http.Request) (*time.Time, error) {
+	v := r.URL.Query().Get("vintage")
+	if v == "" {
+		return nil, nil
+	}
+
+	vintage, err := time.Parse("2006-01-02", v)
+	if err != nil {
+		return nil, fmt.Errorf("invalid vintage date %q, expected YYYY-MM-DD", v)
+	}
+	if vintage.After(time.Now()) {
+		return nil, fmt.Errorf("vintage date %s is in the future", v)
+	}
+
+	return &vintage, nil
+}
+
+// rangeStart returns the observation start for a range, counting back from
+// the vintage date for point-in-time charts
+func rangeStart(rangeParam string, vintage *time.Time) *time.Time {
+	if vintage != nil {
+		return charts.CalculateRangeStartAt(rangeParam, *vintage)
+	}
+	return charts.CalculateRangeStart(rangeParam)
+}
+
+// withVintage limits opts to the observations as they were published on the
+// vintage date. It does nothing if vintage is nil.
+func withVintage(opts *fred.FetchOptions, vintage *time.Time) *fred.FetchOptions {
+	if vintage != nil {
+		opts.ObservationEnd = vintage
+		opts.RealtimeStart = vintage
+		opts.RealtimeEnd = vintage
+	}
+	return opts
+}
+
+// vintageKey formats a vintage date for use in cache keys
+func vintageKey(vintage *time.Time) string {
+	if vintage == nil {
+		return "latest"
+	}
+	return vintage.Format("2006-01-02")
+}
+
+// downloadQuery builds the query string for a chart's download links from
+// the chart request, carrying over the range, overlay, vintage and any other
+// params given
+func downloadQuery(r *http.Request, overlayParam string, params ...string) string {
+	query := url.Values{}
+
+	rangeParam := r.URL.Query().Get("range")
+	if rangeParam == "" {
+		rangeParam = "max"
+	}
+	query.Set("range", rangeParam)
+
+	if r.URL.Query().Get(overlayParam) == "on" {
+		query.Set(overlayParam, "on")
+	}
+	if vintage := r.URL.Query().Get("vintage"); vintage != "" {
+		query.Set("vintage", vintage)
+	}
+	for _, param := range params {
+		if v := r.URL.Query().Get(param); v != "" {
+			query.Set(param, v)
+		}
+	}
+
+	return query.Encode()
+}
+
+// csvValue formats a value for CSV downloads, leaving missing values empty
+func csvValue(v float64) string {
+	if math.IsNaN(v) {
+		return ""
+	}
+	return fmt.Sprintf("%.6f", v)
+}
+
+// writeChartJSON writes chart data as a JSON download. With report=on, which
+// the download links set, the data is wrapped in an object alongside the
+// FRED fetch reports, showing how many rows were missing or skipped for each
+// series.
+func writeChartJSON(w http.ResponseWriter, r *http.Request, result *chartResult) {
+	var v any = result.Data
+	if r.URL.Query().Get("report") == "on" {
+		v = struct {
+			Data    []templates.LineChartData `json:"data"`
+			Reports []fred.FetchReport        `json:"reports"`
+		}{
+			Data:    result.Data,
+			Reports: result.Reports,
+		}
+	}
+
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Print("failed to encode JSON:", err)
+	}
+}
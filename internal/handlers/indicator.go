@@ -0,0 +1,247 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/shanehull/shanehull.com/internal/fred"
+	"github.com/shanehull/shanehull.com/internal/templates"
+)
+
+// Indicator is a chart tool computed from FRED series. Each registered
+// indicator is served at /{slug}/chart, /{slug}/downloads, /{slug}/data and
+// /{slug}/data.csv, and its inputs are listed at /{slug}/sources.
+type Indicator interface {
+	// Slug is the indicator's URL path segment
+	Slug() string
+
+	// Inputs are the FRED series the indicator is computed from
+	Inputs() []Input
+
+	// Labels are the chart and download labels
+	Labels() Labels
+
+	// Overlay is the optional overlay the chart can show
+	Overlay() Overlay
+
+	// Compute calculates the indicator from the full history of each input,
+	// keyed by series ID. start is the beginning of the selected range, or
+	// nil for the full history; points before it are dropped afterwards, so
+	// it only needs to be used by indicators that are scaled over the range.
+	Compute(series map[string][]fred.DataPoint, start *time.Time) ([]fred.DataPoint, error)
+}
+
+// Shaded is implemented by indicators that shade periods of their chart,
+// such as yield curve inversions
+type Shaded interface {
+	// Bands returns the periods to shade on a chart of data
+	Bands(data []templates.LineChartData) []templates.ChartBand
+}
+
+// Parameterized is implemented by indicators with variants selected by
+// query params, such as the series or frequency shown. The indicator itself
+// is the default variant, which is warmed and listed as the data sources.
+type Parameterized interface {
+	// Variant returns the variant a request is for, or an error if its
+	// params are invalid
+	Variant(r *http.Request) (Indicator, error)
+
+	// VariantParams are the query params that select a variant, which are
+	// carried over to download links
+	VariantParams() []string
+}
+
+// Input is a FRED series used by an indicator, the role it plays and the
+// options its full history is fetched with. Nil options fetch the series
+// with the FRED defaults.
+type Input struct {
+	Role     string
+	SeriesID string
+	Options  *fred.FetchOptions
+}
+
+// Labels are the text shown for an indicator
+type Labels struct {
+	// Title labels the line on the chart
+	Title string
+
+	// YAxis labels the chart's y axis
+	YAxis string
+
+	// Column is the CSV column header for the indicator's values
+	Column string
+}
+
+// Overlay is an optional chart overlay. Its value is the query param that
+// turns it on.
+type Overlay string
+
+const (
+	OverlayNone      Overlay = ""
+	OverlayQuartiles Overlay = "quartiles"
+	OverlayAverage   Overlay = "average"
+)
+
+// indicator is an Indicator defined by its fields
+type indicator struct {
+	slug    string
+	inputs  []Input
+	labels  Labels
+	overlay Overlay
+	compute func(series map[string][]fred.DataPoint, start *time.Time) ([]fred.DataPoint, error)
+
+	// shade optionally returns the periods to shade on the chart
+	shade func(data []templates.LineChartData) []templates.ChartBand
+}
+
+func (i *indicator) Slug() string     { return i.slug }
+func (i *indicator) Inputs() []Input  { return i.inputs }
+func (i *indicator) Labels() Labels   { return i.labels }
+func (i *indicator) Overlay() Overlay { return i.overlay }
+
+func (i *indicator) Compute(series map[string][]fred.DataPoint, start *time.Time) ([]fred.DataPoint, error) {
+	return i.compute(series, start)
+}
+
+func (i *indicator) Bands(data []templates.LineChartData) []templates.ChartBand {
+	if i.shade == nil {
+		return nil
+	}
+	return i.shade(data)
+}
+
+// builtinIndicators are the indicators defined in Go that every
+// ChartHandlers serves, ahead of those defined in YAML
+var builtinIndicators = []Indicator{
+	msIndex,
+	yieldCurveIndicator,
+}
+
+// errNoData is returned when an indicator has no values in the selected
+// range
+var errNoData = errors.New("no data available for the selected time range")
+
+var slugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// Register adds an indicator to be served by Routes. Slugs must be unique
+// lowercase words separated by hyphens.
+func (h *ChartHandlers) Register(ind Indicator) error {
+	slug := ind.Slug()
+	if !slugPattern.MatchString(slug) || slug == customSlug {
+		return fmt.Errorf("invalid indicator slug %q", slug)
+	}
+	if _, ok := h.indicators[slug]; ok {
+		return fmt.Errorf("indicator %q is already registered", slug)
+	}
+	if len(ind.Inputs()) == 0 {
+		return fmt.Errorf("indicator %q has no inputs", slug)
+	}
+	switch ind.Overlay() {
+	case OverlayNone, OverlayQuartiles, OverlayAverage:
+	default:
+		return fmt.Errorf("indicator %q has unknown overlay %q", slug, ind.Overlay())
+	}
+
+	h.indicators[slug] = ind
+	h.order = append(h.order, slug)
+	return nil
+}
+
+// Indicators returns the registered indicators in registration order
+func (h *ChartHandlers) Indicators() []Indicator {
+	inds := make([]Indicator, len(h.order))
+	for i, slug := range h.order {
+		inds[i] = h.indicators[slug]
+	}
+	return inds
+}
+
+// indicator returns the registered indicator with the given slug
+func (h *ChartHandlers) indicator(slug string) (Indicator, bool) {
+	ind, ok := h.indicators[slug]
+	return ind, ok
+}
+
+// inputRequests returns the requests for the full history of each input.
+// Every request has options, since they are part of the cache key.
+func inputRequests(inputs []Input) []fred.SeriesRequest {
+	requests := make([]fred.SeriesRequest, len(inputs))
+	for i, in := range inputs {
+		opts := in.Options
+		if opts == nil {
+			opts = &fred.FetchOptions{}
+		}
+		requests[i] = fred.SeriesRequest{ID: in.SeriesID, Options: opts}
+	}
+	return requests
+}
+
+// getOrFetchIndicator computes an indicator's chart data over a range from
+// the cached history of its inputs, with its overlay if showOverlay is set
+func (h *ChartHandlers) getOrFetchIndicator(ctx context.Context, ind Indicator, rangeParam string, showOverlay bool, vintage *time.Time) (*chartResult, error) {
+	c := h.seriesCache
+	if ind.Slug() == customSlug {
+		c = h.customCache
+	}
+
+	inputs := ind.Inputs()
+	set, err := h.getOrFetchSeries(ctx, c, vintage, inputRequests(inputs)...)
+	if err != nil {
+		return nil, err
+	}
+
+	startDate := rangeStart(rangeParam, vintage)
+	points, err := h.compute(ind, set, startDate, vintage)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute %s: %w", ind.Slug(), err)
+	}
+	points = pointsFrom(points, startDate)
+	if len(points) == 0 {
+		return nil, errNoData
+	}
+
+	values := make([]float64, len(points))
+	for i, p := range points {
+		values[i] = p.Value
+	}
+
+	var q1, q3 []float64
+	var avg float64
+	if showOverlay {
+		switch ind.Overlay() {
+		case OverlayQuartiles:
+			q1, q3 = calculateQuartiles(values)
+		case OverlayAverage:
+			avg = average(values)
+		}
+	}
+
+	chartData := make([]templates.LineChartData, len(points))
+	for i, p := range points {
+		chartData[i] = templates.LineChartData{
+			Date:    p.Date.Format("2006-01-02"),
+			Value:   p.Value,
+			Average: avg,
+			Missing: math.IsNaN(p.Value),
+		}
+		if i < len(q1) && i < len(q3) {
+			chartData[i].Quartile1 = q1[i]
+			chartData[i].Quartile3 = q3[i]
+		}
+	}
+
+	ids := make([]string, len(inputs))
+	for i, in := range inputs {
+		ids[i] = in.SeriesID
+	}
+	result := set.result(chartData, ids...)
+	if shaded, ok := ind.(Shaded); ok {
+		result.Bands = shaded.Bands(chartData)
+	}
+	return result, nil
+}
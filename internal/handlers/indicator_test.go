@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shanehull/shanehull.com/internal/fred"
+)
+
+func TestRegisterNilOptions(t *testing.T) {
+	h := newTestHandlers(t, newTestServer(t))
+
+	// GDP is quarterly, FRED's default frequency
+	err := h.Register(&indicator{
+		slug:   "gdp",
+		inputs: []Input{{Role: "GDP", SeriesID: "GDP"}},
+		labels: Labels{Title: "GDP", Column: "gdp"},
+		compute: func(series map[string][]fred.DataPoint, _ *time.Time) ([]fred.DataPoint, error) {
+			return series["GDP"], nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+
+	rec := serve(h, http.MethodGet, "/gdp/chart?recessions=on")
+	if strings.Contains(rec.Body.String(), "chart-error") {
+		t.Fatalf("chart error: %s", rec.Body)
+	}
+	if config := parseChart(t, rec.Body.String()); len(config.Labels) == 0 {
+		t.Error("chart has no data")
+	}
+	for _, target := range []string{"/gdp/data", "/gdp/data.csv"} {
+		if rec := serve(h, http.MethodGet, target); rec.Code != http.StatusOK {
+			t.Errorf("GET %s = %d: %.200s", target, rec.Code, rec.Body)
+		}
+	}
+	if rec := serve(h, http.MethodPost, "/admin/cache/refresh/gdp"); rec.Code != http.StatusOK {
+		t.Errorf("POST refresh = %d: %s", rec.Code, rec.Body)
+	}
+}
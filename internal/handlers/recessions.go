@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/shanehull/shanehull.com/internal/fred"
+	"github.com/shanehull/shanehull.com/internal/templates"
+)
+
+const (
+	recessionMonthlyID   = "USREC"
+	recessionQuarterlyID = "USRECQ"
+)
+
+// recessionColor shades NBER recessions
+const recessionColor = "rgba(107, 114, 128, 0.2)"
+
+// showRecessions reports whether the request turns on recession shading,
+// which any chart can show alongside its own overlay
+func showRecessions(r *http.Request) bool {
+	return r.URL.Query().Get("recessions") == "on"
+}
+
+// recessionBands returns a band for each NBER recession that overlaps data,
+// clipped to its dates. Quarterly and annual charts use the quarterly
+// recession indicator, since a short recession can fall between their dates,
+// and other charts the monthly one.
+func (h *ChartHandlers) recessionBands(ctx context.Context, ind Indicator, data []templates.LineChartData, vintage *time.Time) ([]templates.ChartBand, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	id, freq := recessionMonthlyID, fred.FrequencyMonthly
+	if inputs := ind.Inputs(); len(inputs) > 0 && inputs[0].Options != nil {
+		switch inputs[0].Options.Frequency {
+		case fred.FrequencyQuarterly, fred.FrequencySemiannual, fred.FrequencyAnnual:
+			id, freq = recessionQuarterlyID, fred.FrequencyQuarterly
+		}
+	}
+
+	set, err := h.getOrFetchSeries(ctx, h.seriesCache, vintage, fred.SeriesRequest{
+		ID: id,
+		Options: &fred.FetchOptions{
+			Frequency: freq,
+			Units:     fred.UnitsLevels,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get recessions: %w", err)
+	}
+
+	first, last := data[0].Date, data[len(data)-1].Date
+
+	var bands []templates.ChartBand
+	for _, run := range recessions(set.Series[id].Points, freq) {
+		// Dates are ISO formatted, so they compare as strings
+		if run.End < first || run.Start > last {
+			continue
+		}
+		run.Start, run.End = max(run.Start, first), min(run.End, last)
+		bands = append(bands, run)
+	}
+	return bands, nil
+}
+
+// recessions returns a band for each run of observations of a recession
+// indicator, which is 1 in a recession and 0 otherwise. Each band ends on
+// the last day of its last period, so that it covers every date of a
+// higher frequency chart.
+func recessions(points []fred.DataPoint, freq fred.Frequency) []templates.ChartBand {
+	months := 1
+	if freq == fred.FrequencyQuarterly {
+		months = 3
+	}
+
+	var bands []templates.ChartBand
+	inRecession := false
+	for _, p := range points {
+		if p.Value != 1 {
+			inRecession = false
+			continue
+		}
+		if !inRecession {
+			bands = append(bands, templates.ChartBand{
+				Start: p.Date.Format("2006-01-02"),
+				Label: "Recession",
+				Color: recessionColor,
+			})
+			inRecession = true
+		}
+		bands[len(bands)-1].End = p.Date.AddDate(0, months, -1).Format("2006-01-02")
+	}
+	return bands
+}
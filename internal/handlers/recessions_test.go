@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"context"
+	"math"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/shanehull/shanehull.com/internal/fred"
+	"github.com/shanehull/shanehull.com/internal/fred/fredtest"
+	"github.com/shanehull/shanehull.com/internal/templates"
+)
+
+// recession is a recession band from start to end
+func recession(start, end string) templates.ChartBand {
+	return templates.ChartBand{Start: start, End: end, Label: "Recession", Color: recessionColor}
+}
+
+func TestRecessions(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		freq   fred.Frequency
+		values []float64
+		want   []templates.ChartBand
+	}{
+		{"none", fred.FrequencyMonthly, []float64{0, 0, 0}, nil},
+		{"to month end", fred.FrequencyMonthly, []float64{0, 1, 1, 0}, []templates.ChartBand{recession("2020-02-01", "2020-03-31")}},
+		{"ongoing", fred.FrequencyMonthly, []float64{0, 0, 1}, []templates.ChartBand{recession("2020-03-01", "2020-03-31")}},
+		{"several", fred.FrequencyMonthly, []float64{1, 0, 1, 1}, []templates.ChartBand{recession("2020-01-01", "2020-01-31"), recession("2020-03-01", "2020-04-30")}},
+		{"broken by a missing value", fred.FrequencyMonthly, []float64{1, math.NaN(), 1}, []templates.ChartBand{recession("2020-01-01", "2020-01-31"), recession("2020-03-01", "2020-03-31")}},
+		{"to quarter end", fred.FrequencyQuarterly, []float64{0, 1, 1, 0}, []templates.ChartBand{recession("2020-04-01", "2020-09-30")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := recessions(fredtest.Points(tt.freq, start, tt.values...), tt.freq)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("recessions() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecessionBands(t *testing.T) {
+	// The test server has a recession through 2008
+	whole := []templates.ChartBand{recession("2008-01-01", "2008-12-31")}
+
+	tests := []struct {
+		name        string
+		freq        fred.Frequency // of the indicator's first input
+		first, last string
+		wantID      string
+		want        []templates.ChartBand
+	}{
+		{"monthly chart", fred.FrequencyMonthly, "2005-01-01", "2012-01-01", recessionMonthlyID, whole},
+		{"daily chart", fred.FrequencyDaily, "2005-01-01", "2012-01-01", recessionMonthlyID, whole},
+		{"default frequency", "", "2005-01-01", "2012-01-01", recessionMonthlyID, whole},
+		{"quarterly chart", fred.FrequencyQuarterly, "2005-01-01", "2012-01-01", recessionQuarterlyID, whole},
+		{"annual chart", fred.FrequencyAnnual, "2005-01-01", "2012-01-01", recessionQuarterlyID, whole},
+		{"clipped start", fred.FrequencyMonthly, "2008-06-01", "2012-01-01", recessionMonthlyID, []templates.ChartBand{recession("2008-06-01", "2008-12-31")}},
+		{"clipped end", fred.FrequencyMonthly, "2005-01-01", "2008-03-01", recessionMonthlyID, []templates.ChartBand{recession("2008-01-01", "2008-03-01")}},
+		{"within the recession", fred.FrequencyQuarterly, "2008-04-01", "2008-07-01", recessionQuarterlyID, []templates.ChartBand{recession("2008-04-01", "2008-07-01")}},
+		{"before the recession", fred.FrequencyMonthly, "2001-01-01", "2007-12-01", recessionMonthlyID, nil},
+		{"after the recession", fred.FrequencyMonthly, "2009-01-01", "2012-01-01", recessionMonthlyID, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newTestServer(t)
+			h := newTestHandlers(t, srv)
+
+			var opts *fred.FetchOptions
+			if tt.freq != "" {
+				opts = &fred.FetchOptions{Frequency: tt.freq}
+			}
+			ind := &indicator{slug: "test", inputs: []Input{{Role: "Test", SeriesID: "GDP", Options: opts}}}
+			data := []templates.LineChartData{{Date: tt.first}, {Date: tt.last}}
+
+			got, err := h.recessionBands(context.Background(), ind, data, nil)
+			if err != nil {
+				t.Fatalf("recessionBands() error = %v", err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("recessionBands() = %v, want %v", got, tt.want)
+			}
+
+			for _, r := range srv.Requests() {
+				if id := r.URL.Query().Get("series_id"); r.URL.Path == "/series/observations" && id != tt.wantID {
+					t.Errorf("fetched %s, want %s", id, tt.wantID)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,273 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/shanehull/shanehull.com/internal/templates"
+)
+
+// Route is a pattern and the handler that serves it
+type Route struct {
+	Pattern string
+	Handler http.HandlerFunc
+}
+
+// indicatorFunc returns the indicator a request is for
+type indicatorFunc func(r *http.Request) (Indicator, error)
+
+// Routes returns the chart, downloads, data and CSV routes of every
+// registered indicator, followed by those of the custom formula tool
+func (h *ChartHandlers) Routes() []Route {
+	var routes []Route
+	for _, ind := range h.Indicators() {
+		resolve := func(*http.Request) (Indicator, error) {
+			return ind, nil
+		}
+		var params []string
+		if p, ok := ind.(Parameterized); ok {
+			resolve = p.Variant
+			params = p.VariantParams()
+		}
+		routes = append(routes, h.indicatorRoutes(ind.Slug(), ind.Overlay(), resolve, params...)...)
+	}
+	return append(routes, h.indicatorRoutes(customSlug, OverlayAverage, h.customIndicator, customParams...)...)
+}
+
+// indicatorRoutes returns the routes for the indicator resolve returns.
+// Download links carry over params along with the range, overlay and
+// vintage.
+func (h *ChartHandlers) indicatorRoutes(slug string, overlay Overlay, resolve indicatorFunc, params ...string) []Route {
+	return []Route{
+		{Pattern: "/" + slug + "/chart", Handler: h.indicatorChartHandler(resolve)},
+		{Pattern: "/" + slug + "/downloads", Handler: indicatorDownloadsHandler(slug, overlay, params...)},
+		{Pattern: "/" + slug + "/data", Handler: h.indicatorDataHandler(resolve)},
+		{Pattern: "/" + slug + "/data.csv", Handler: h.indicatorCSVHandler(resolve)},
+	}
+}
+
+// showOverlay reports whether the request turns on the indicator's overlay
+func showOverlay(r *http.Request, ind Indicator) bool {
+	return ind.Overlay() != OverlayNone && r.URL.Query().Get(string(ind.Overlay())) == "on"
+}
+
+// rangeParam returns the requested chart range, defaulting to max
+func rangeParam(r *http.Request) string {
+	rangeParam := r.URL.Query().Get("range")
+	if rangeParam == "" {
+		rangeParam = "max"
+	}
+	return rangeParam
+}
+
+// indicatorChartHandler renders an indicator's chart
+func (h *ChartHandlers) indicatorChartHandler(resolve indicatorFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		ind, err := resolve(r)
+		if err != nil {
+			renderError(w, err.Error())
+			return
+		}
+
+		overlay := showOverlay(r, ind)
+
+		vintage, err := parseVintage(r)
+		if err != nil {
+			renderError(w, err.Error())
+			return
+		}
+
+		result, err := h.getOrFetchIndicator(r.Context(), ind, rangeParam(r), overlay, vintage)
+		if err != nil {
+			log.Print("failed to get chart data:", err)
+			_, msg := chartDataError(err)
+			renderError(w, msg)
+			return
+		}
+
+		// Recessions are drawn underneath the indicator's own bands, and
+		// the chart is still shown if they can't be loaded
+		if showRecessions(r) {
+			recessions, err := h.recessionBands(r.Context(), ind, result.Data, vintage)
+			if err != nil {
+				log.Print("failed to get recession bands:", err)
+			}
+			result.Bands = append(recessions, result.Bands...)
+		}
+
+		labels := ind.Labels()
+		options := map[string]string{
+			"mainLabel":     labels.Title,
+			"yAxisLabel":    labels.YAxis,
+			"showQuartiles": "false",
+			"showAverage":   "false",
+		}
+		showQuartiles := overlay && ind.Overlay() == OverlayQuartiles
+		if showQuartiles {
+			options["showQuartiles"] = "true"
+		}
+		if overlay && ind.Overlay() == OverlayAverage {
+			options["showAverage"] = "true"
+		}
+		setDataAsOf(options, result)
+		component := templates.LineChart("chart-canvas", result.Data, showQuartiles, options, result.Bands)
+
+		buf := new(bytes.Buffer)
+		defer buf.Reset()
+
+		if renderErr := component.Render(r.Context(), buf); renderErr != nil {
+			// Suppress context canceled errors - common when client disconnects
+			if renderErr.Error() != "context canceled" {
+				log.Print("failed to render component:", renderErr)
+			}
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("HX-Trigger", "initChartFromData")
+		_, err = w.Write(buf.Bytes())
+		if err != nil {
+			log.Print("failed to write response:", err)
+		}
+	}
+}
+
+// indicatorDownloadsHandler renders the download links for an indicator's
+// chart
+func indicatorDownloadsHandler(slug string, overlay Overlay, params ...string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		component := templates.ChartDownloads(slug, downloadQuery(r, string(overlay), params...))
+
+		buf := new(bytes.Buffer)
+		defer buf.Reset()
+
+		if err := component.Render(r.Context(), buf); err != nil {
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if _, err := w.Write(buf.Bytes()); err != nil {
+			log.Print("failed to write response:", err)
+		}
+	}
+}
+
+// indicatorDataHandler serves an indicator's chart data as a JSON download
+func (h *ChartHandlers) indicatorDataHandler(resolve indicatorFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		ind, err := resolve(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		vintage, err := parseVintage(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		result, err := h.getOrFetchIndicator(r.Context(), ind, rangeParam(r), showOverlay(r, ind), vintage)
+		if err != nil {
+			log.Print("failed to get chart data:", err)
+			status, msg := chartDataError(err)
+			http.Error(w, msg, status)
+			return
+		}
+
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", ind.Slug()+"-data.json"))
+
+		writeChartJSON(w, r, result)
+	}
+}
+
+// indicatorCSVHandler serves an indicator's chart data as a CSV download
+func (h *ChartHandlers) indicatorCSVHandler(resolve indicatorFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		ind, err := resolve(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		overlay := showOverlay(r, ind)
+
+		vintage, err := parseVintage(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		result, err := h.getOrFetchIndicator(r.Context(), ind, rangeParam(r), overlay, vintage)
+		if err != nil {
+			log.Print("failed to get chart data:", err)
+			status, msg := chartDataError(err)
+			http.Error(w, msg, status)
+			return
+		}
+
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", ind.Slug()+"-data.csv"))
+
+		writer := csv.NewWriter(w)
+		defer writer.Flush()
+
+		header := []string{"date", ind.Labels().Column}
+		if overlay {
+			switch ind.Overlay() {
+			case OverlayQuartiles:
+				header = append(header, "quartile1", "quartile3")
+			case OverlayAverage:
+				header = append(header, "average")
+			}
+		}
+		if err := writer.Write(header); err != nil {
+			log.Print("failed to write CSV header:", err)
+			return
+		}
+
+		for _, d := range result.Data {
+			row := []string{
+				d.Date,
+				csvValue(d.Value),
+			}
+
+			if overlay {
+				switch ind.Overlay() {
+				case OverlayQuartiles:
+					row = append(row, fmt.Sprintf("%.6f", d.Quartile1), fmt.Sprintf("%.6f", d.Quartile3))
+				case OverlayAverage:
+					row = append(row, fmt.Sprintf("%.6f", d.Average))
+				}
+			}
+
+			if err := writer.Write(row); err != nil {
+				log.Print("failed to write CSV row:", err)
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,254 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shanehull/shanehull.com/internal/fred"
+	"github.com/shanehull/shanehull.com/internal/fred/fredtest"
+	"github.com/shanehull/shanehull.com/internal/templates"
+)
+
+func TestIndicatorRoutes(t *testing.T) {
+	h := newTestHandlers(t, newTestServer(t))
+
+	tests := []struct {
+		slug     string
+		column   string
+		overlay  []string
+		datasets []string
+	}{
+		{"msindex", "msindex", []string{"quartile1", "quartile3"}, []string{"Q1 (25th percentile)", "Q3 (75th percentile)"}},
+		{"buffett-indicator", "buffett_indicator", []string{"average"}, []string{"Average"}},
+		{"real-interest-rate", "real_interest_rate", []string{"average"}, []string{"Average"}},
+	}
+	for _, tt := range tests {
+		ind, ok := h.indicator(tt.slug)
+		if !ok {
+			t.Fatalf("%s is not registered", tt.slug)
+		}
+		overlayParam := "&" + string(ind.Overlay()) + "=on"
+
+		for _, rangeParam := range []string{"5y", "10y", "20y", "max"} {
+			for _, overlay := range []bool{false, true} {
+				query := "?range=" + rangeParam
+				if overlay {
+					query += overlayParam
+				}
+
+				t.Run(tt.slug+query, func(t *testing.T) {
+					rec := serve(h, http.MethodGet, "/"+tt.slug+"/chart"+query)
+					if rec.Code != http.StatusOK || strings.Contains(rec.Body.String(), "chart-error") {
+						t.Fatalf("GET chart = %d: %s", rec.Code, rec.Body)
+					}
+					config := parseChart(t, rec.Body.String())
+					if config.Datasets[0].Label != ind.Labels().Title || config.YAxisLabel != ind.Labels().YAxis {
+						t.Errorf("chart labels = %q, %q, want %q, %q", config.Datasets[0].Label, config.YAxisLabel, ind.Labels().Title, ind.Labels().YAxis)
+					}
+					var labels []string
+					for _, ds := range config.Datasets[1:] {
+						labels = append(labels, ds.Label)
+					}
+					if want := tt.datasets; !overlay && len(labels) > 0 || overlay && !slices.Equal(labels, want) {
+						t.Errorf("overlay datasets = %q, want %q", labels, want)
+					}
+
+					rec = serve(h, http.MethodGet, "/"+tt.slug+"/data"+query)
+					var data []templates.LineChartData
+					if err := json.NewDecoder(rec.Body).Decode(&data); err != nil || rec.Code != http.StatusOK {
+						t.Fatalf("GET data = %d, %v", rec.Code, err)
+					}
+					if len(data) != len(config.Labels) {
+						t.Errorf("data has %d points, chart has %d", len(data), len(config.Labels))
+					}
+					if start := rangeStart(rangeParam, nil); start != nil && data[0].Date < start.Format("2006-01-02") {
+						t.Errorf("data starts %s, before the range starts", data[0].Date)
+					}
+
+					rec = serve(h, http.MethodGet, "/"+tt.slug+"/data.csv"+query)
+					rows, err := csv.NewReader(rec.Body).ReadAll()
+					if err != nil || rec.Code != http.StatusOK {
+						t.Fatalf("GET data.csv = %d, %v", rec.Code, err)
+					}
+					header := []string{"date", tt.column}
+					if overlay {
+						header = append(header, tt.overlay...)
+					}
+					if !slices.Equal(rows[0], header) {
+						t.Errorf("CSV header = %v, want %v", rows[0], header)
+					}
+					if len(rows)-1 != len(data) || rows[1][0] != data[0].Date {
+						t.Errorf("CSV has %d rows from %s, want %d from %s", len(rows)-1, rows[1][0], len(data), data[0].Date)
+					}
+				})
+			}
+		}
+
+		t.Run(tt.slug+"/downloads", func(t *testing.T) {
+			rec := serve(h, http.MethodGet, "/"+tt.slug+"/downloads?range=10y"+overlayParam)
+			for _, want := range []string{"/" + tt.slug + "/data.csv?", "range=10y"} {
+				if !strings.Contains(rec.Body.String(), want) {
+					t.Errorf("downloads = %s, want a link with %s", rec.Body, want)
+				}
+			}
+		})
+
+		t.Run(tt.slug+"/sources", func(t *testing.T) {
+			rec := serve(h, http.MethodGet, "/"+tt.slug+"/sources")
+			for _, in := range ind.Inputs() {
+				if !strings.Contains(rec.Body.String(), in.SeriesID) {
+					t.Errorf("sources = %s, want %s", rec.Body, in.SeriesID)
+				}
+			}
+		})
+	}
+}
+
+func TestIndicatorRoutesVintage(t *testing.T) {
+	srv := newTestServer(t)
+	h := newTestHandlers(t, srv)
+
+	// observations returns the observations requests made since the last call
+	seen := 0
+	observations := func() []*http.Request {
+		requests := srv.Requests()
+		var obs []*http.Request
+		for _, r := range requests[seen:] {
+			if r.URL.Path == "/series/observations" {
+				obs = append(obs, r)
+			}
+		}
+		seen = len(requests)
+		return obs
+	}
+	data := func(target string) []templates.LineChartData {
+		t.Helper()
+		rec := serve(h, http.MethodGet, target)
+		var data []templates.LineChartData
+		if err := json.NewDecoder(rec.Body).Decode(&data); err != nil || rec.Code != http.StatusOK {
+			t.Fatalf("GET %s = %d, %v", target, rec.Code, err)
+		}
+		return data
+	}
+
+	const vintage = "2015-06-30"
+	points := data("/buffett-indicator/data?range=max&vintage=" + vintage)
+	if last := points[len(points)-1].Date; last > vintage {
+		t.Errorf("vintage data ends %s, after the vintage", last)
+	}
+
+	// Every input is fetched as it was known on the vintage date
+	requests := observations()
+	if len(requests) != len(h.indicators["buffett-indicator"].Inputs()) {
+		t.Errorf("made %d observations requests for the vintage, want one per input", len(requests))
+	}
+	for _, r := range requests {
+		q := r.URL.Query()
+		for _, param := range []string{"realtime_start", "realtime_end", "observation_end"} {
+			if got := q.Get(param); got != vintage {
+				t.Errorf("%s request has %s = %q, want %q", q.Get("series_id"), param, got, vintage)
+			}
+		}
+	}
+
+	// Current data is cached separately from the vintage
+	current := data("/buffett-indicator/data?range=max")
+	if current[len(current)-1].Date <= vintage {
+		t.Errorf("current data ends %s, want data after the vintage", current[len(current)-1].Date)
+	}
+	requests = observations()
+	if len(requests) == 0 {
+		t.Error("current data was served from the vintage's cache")
+	}
+	for _, r := range requests {
+		if q := r.URL.Query(); q.Has("realtime_start") || q.Has("realtime_end") {
+			t.Errorf("current %s request has a real-time period: %s", q.Get("series_id"), r.URL.RawQuery)
+		}
+	}
+
+	// Both are now served from the cache
+	data("/buffett-indicator/data?range=max&vintage=" + vintage)
+	data("/buffett-indicator/data?range=max")
+	if requests := observations(); len(requests) != 0 {
+		t.Errorf("made %d observations requests for cached data, want none", len(requests))
+	}
+}
+
+func TestIndicatorRouteErrors(t *testing.T) {
+	// GDP is missing, so the Buffett Indicator can't be drawn
+	partial := func(t *testing.T) *fredtest.Server {
+		srv := fredtest.NewServer()
+		t.Cleanup(srv.Close)
+		srv.AddSeries("NCBEILQ027S", fred.FrequencyQuarterly, fredtest.Points(fred.FrequencyQuarterly, testStart, trend(80, 100)...))
+		return srv
+	}
+	failing := func(status int) func(t *testing.T) *fredtest.Server {
+		return func(t *testing.T) *fredtest.Server {
+			srv := newTestServer(t)
+			srv.FailNext(slices.Repeat([]int{status}, 20)...)
+			return srv
+		}
+	}
+
+	tomorrow := time.Now().AddDate(0, 0, 1).Format("2006-01-02")
+	tests := []struct {
+		name   string
+		server func(t *testing.T) *fredtest.Server
+		target string
+		status int
+		msg    string
+	}{
+		{"no data in range", newTestServer, "/buffett-indicator/%s?range=1y", http.StatusNotFound, "No data is available for the selected time range."},
+		{"missing series", partial, "/buffett-indicator/%s", http.StatusBadGateway, "(GDP) has been discontinued"},
+		{"FRED unavailable", failing(http.StatusInternalServerError), "/buffett-indicator/%s", http.StatusBadGateway, "FRED is unavailable right now."},
+		{"rate limited", failing(http.StatusTooManyRequests), "/buffett-indicator/%s", http.StatusServiceUnavailable, "FRED is busy right now."},
+		{"invalid vintage", newTestServer, "/msindex/%s?vintage=2020-13-01", http.StatusBadRequest, "invalid vintage date"},
+		{"future vintage", newTestServer, "/msindex/%s?vintage=" + tomorrow, http.StatusBadRequest, "is in the future"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newTestHandlers(t, tt.server(t))
+
+			// Charts are htmx partials, so errors are shown in the chart
+			rec := serve(h, http.MethodGet, strings.Replace(tt.target, "%s", "chart", 1))
+			if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "chart-error") || !strings.Contains(rec.Body.String(), tt.msg) {
+				t.Errorf("GET chart = %d: %.200s, want an error containing %q", rec.Code, rec.Body, tt.msg)
+			}
+
+			for _, route := range []string{"data", "data.csv"} {
+				rec := serve(h, http.MethodGet, strings.Replace(tt.target, "%s", route, 1))
+				if rec.Code != tt.status || !strings.Contains(rec.Body.String(), tt.msg) {
+					t.Errorf("GET %s = %d: %.200s, want %d %q", route, rec.Code, rec.Body, tt.status, tt.msg)
+				}
+			}
+		})
+	}
+}
+
+func TestIndicatorRoutesBadAPIKey(t *testing.T) {
+	srv := newTestServer(t)
+	h := newTestHandlers(t, srv)
+	srv.APIKey = "revoked"
+
+	// The key is a server problem, not shown to users
+	rec := serve(h, http.MethodGet, "/buffett-indicator/data")
+	if rec.Code != http.StatusInternalServerError || strings.TrimSpace(rec.Body.String()) != "Chart data is temporarily unavailable." {
+		t.Errorf("GET data = %d: %.200s, want 500", rec.Code, rec.Body)
+	}
+}
+
+func TestIndicatorRoutesMethodNotAllowed(t *testing.T) {
+	h := newTestHandlers(t, newTestServer(t))
+
+	for _, route := range []string{"chart", "downloads", "data", "data.csv", "sources"} {
+		rec := serve(h, http.MethodPost, "/msindex/"+route)
+		if rec.Code != http.StatusMethodNotAllowed || strings.TrimSpace(rec.Body.String()) != "method not allowed" {
+			t.Errorf("POST %s = %d: %s, want 405", route, rec.Code, rec.Body)
+		}
+	}
+}
@@ -0,0 +1,272 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shanehull/shanehull.com/internal/cache"
+	"github.com/shanehull/shanehull.com/internal/fred"
+	"github.com/shanehull/shanehull.com/internal/templates"
+)
+
+const (
+	// seriesCacheTTL is used when a series' release calendar is unavailable
+	// or has no upcoming release
+	seriesCacheTTL = 24 * time.Hour
+
+	// Series are otherwise kept until their next release, but for at least
+	// minSeriesCacheTTL, so they aren't refetched on every request in the
+	// run up to a release, and at most maxSeriesCacheTTL
+	minSeriesCacheTTL = time.Hour
+	maxSeriesCacheTTL = 31 * 24 * time.Hour
+
+	// releaseTime is when data is assumed to be available on a release date.
+	// Most FRED sources publish by midday US Eastern time.
+	releaseTime = 17 * time.Hour
+
+	// seriesCacheEntries bounds the series cache. Keys include the vintage,
+	// which is user supplied, so they are not otherwise bounded.
+	seriesCacheEntries = 256
+
+	// Release calendars are kept for releaseCacheTTL, since they rarely
+	// change, and bounded like the series cache
+	releaseCacheTTL     = 24 * time.Hour
+	releaseCacheEntries = seriesCacheEntries
+
+	// seriesCacheBytes bounds the estimated size of the series cache, and
+	// dataPointSize is the estimated size of one observation
+	seriesCacheBytes = 64 << 20
+	dataPointSize    = 64
+
+	// Custom formulas can use any series, so their series are cached apart
+	// from the indicators', within smaller bounds, so that custom charts
+	// can't evict the series the indicators use
+	customCacheEntries = 64
+	customCacheBytes   = 16 << 20
+)
+
+// seriesCache caches the full history of series. Keys start with the
+// cache's name, so caches sharing a cache.Store don't share keys.
+type seriesCache struct {
+	*cache.Cache[string, *fred.Series]
+	name string
+}
+
+// newSeriesCache returns a series cache bounded by entries and estimated
+// size
+func newSeriesCache(name string, maxEntries int, maxBytes int64, opts ...cache.Option) *seriesCache {
+	opts = append([]cache.Option{cache.WithMaxEntries(maxEntries)}, opts...)
+	return &seriesCache{
+		Cache: cache.NewSized[string](maxBytes, seriesSize, opts...),
+		name:  name,
+	}
+}
+
+// seriesSet is the full history of the series behind a chart
+type seriesSet struct {
+	Series map[string]*fred.Series
+
+	// UpdatedAt is when the least recently fetched of the series was fetched
+	UpdatedAt time.Time
+
+	// RefreshErr is set when a stale series is served because refreshing it
+	// failed
+	RefreshErr error
+}
+
+// key is the cache key for a series fetched with the given options
+func (c *seriesCache) key(id string, opts *fred.FetchOptions, vintage *time.Time) string {
+	return fmt.Sprintf("%s:%s:%s:%s:%s:%t:%s", c.name, id, opts.Frequency, opts.AggregationMethod, opts.Units, opts.KeepMissing, vintageKey(vintage))
+}
+
+// parseKey returns the request a cache key was made from. Only keys for the
+// latest data are parsed, since vintages never change.
+func (c *seriesCache) parseKey(key string) (fred.SeriesRequest, bool) {
+	parts := strings.Split(key, ":")
+	if len(parts) != 7 || parts[0] != c.name || parts[6] != vintageKey(nil) {
+		return fred.SeriesRequest{}, false
+	}
+	keepMissing, err := strconv.ParseBool(parts[5])
+	if err != nil {
+		return fred.SeriesRequest{}, false
+	}
+
+	return fred.SeriesRequest{
+		ID: parts[1],
+		Options: &fred.FetchOptions{
+			Frequency:         fred.Frequency(parts[2]),
+			AggregationMethod: fred.AggregationMethod(parts[3]),
+			Units:             fred.Units(parts[4]),
+			KeepMissing:       keepMissing,
+		},
+	}, true
+}
+
+// getOrFetchSeries returns the full history of each requested series from
+// c, fetching any that are not cached concurrently. Requests should not set an
+// observation range; charts filter the cached history by range instead, so
+// that every range is served from the same cache entry.
+func (h *ChartHandlers) getOrFetchSeries(ctx context.Context, c *seriesCache, vintage *time.Time, requests ...fred.SeriesRequest) (*seriesSet, error) {
+	set := &seriesSet{}
+
+	var mu sync.Mutex
+	series, err := fred.FetchManyWith(ctx, requests, func(ctx context.Context, req fred.SeriesRequest) (*fred.Series, error) {
+		opts := *req.Options
+		withVintage(&opts, vintage)
+
+		entry, err := c.GetOrLoadEntry(ctx, c.key(req.ID, &opts, vintage), h.loadSeries(req.ID, &opts, vintage))
+		if err != nil {
+			return nil, err
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		if set.UpdatedAt.IsZero() || entry.UpdatedAt.Before(set.UpdatedAt) {
+			set.UpdatedAt = entry.UpdatedAt
+		}
+		if entry.Stale && entry.RefreshErr != nil {
+			set.RefreshErr = entry.RefreshErr
+		}
+		return entry.Value, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	set.Series = series
+	return set, nil
+}
+
+// loadSeries returns a cache loader that fetches the full history of a
+// series and keeps it until the series' next release
+func (h *ChartHandlers) loadSeries(id string, opts *fred.FetchOptions, vintage *time.Time) cache.Loader[*fred.Series] {
+	return func(ctx context.Context) (*fred.Series, time.Duration, error) {
+		series, err := h.fred.FetchSeriesWithReport(ctx, id, opts)
+		if err != nil {
+			return nil, 0, err
+		}
+		if series.Report.Missing > 0 || series.Report.Skipped() > 0 {
+			log.Print("FRED fetch report: ", series.Report)
+		}
+		return series, h.seriesTTL(ctx, id, vintage), nil
+	}
+}
+
+// refreshSeries refetches the latest history of every copy in c of the
+// series with the given IDs, and of the given requests whether cached or
+// not, replacing the cached copies. Copies that fail to refresh are kept.
+// It returns the keys of the copies refreshed.
+func (h *ChartHandlers) refreshSeries(ctx context.Context, c *seriesCache, ids []string, requests ...fred.SeriesRequest) ([]string, error) {
+	refresh := make(map[string]fred.SeriesRequest)
+	for _, req := range requests {
+		refresh[c.key(req.ID, req.Options, nil)] = req
+	}
+	for _, entry := range c.Entries() {
+		if req, ok := c.parseKey(entry.Key); ok && slices.Contains(ids, req.ID) {
+			refresh[entry.Key] = req
+		}
+	}
+
+	keys := slices.Sorted(maps.Keys(refresh))
+	errs := make(map[string]error)
+	for _, key := range keys {
+		req := refresh[key]
+		if _, err := c.Reload(ctx, key, h.loadSeries(req.ID, req.Options, nil)); err != nil {
+			errs[req.ID] = err
+		}
+	}
+
+	if len(errs) > 0 {
+		return keys, &fred.FetchError{Errors: errs}
+	}
+	return keys, nil
+}
+
+// seriesSize estimates the memory used by a cached series
+func seriesSize(s *fred.Series) int64 {
+	return int64(len(s.Points)) * dataPointSize
+}
+
+// seriesTTL returns how long to cache a series: until its next scheduled
+// release, or for the maximum if it is a vintage, which never changes
+func (h *ChartHandlers) seriesTTL(ctx context.Context, id string, vintage *time.Time) time.Duration {
+	if vintage != nil {
+		return maxSeriesCacheTTL
+	}
+
+	dates, err := h.releaseDates(ctx, id)
+	if err != nil {
+		log.Print("failed to get release dates:", err)
+		return seriesCacheTTL
+	}
+
+	return releaseTTL(dates, time.Now())
+}
+
+// releaseDates returns the scheduled release dates of a series. A series
+// that isn't part of a release has none.
+func (h *ChartHandlers) releaseDates(ctx context.Context, id string) ([]time.Time, error) {
+	return h.releaseCache.GetOrLoad(ctx, "release:"+id, func(ctx context.Context) ([]time.Time, time.Duration, error) {
+		dates, err := h.fred.FetchSeriesReleaseDates(ctx, id)
+		if errors.Is(err, fred.ErrNoScheduledRelease) {
+			return nil, releaseCacheTTL, nil
+		}
+		return dates, releaseCacheTTL, err
+	})
+}
+
+// releaseTTL returns the time from now until data from the next release is
+// available, within the series cache TTL bounds. Data from a release is
+// assumed to be available from releaseTime on its date, so once that has
+// passed the following release is used.
+func releaseTTL(dates []time.Time, now time.Time) time.Duration {
+	for _, date := range dates {
+		if available := date.Add(releaseTime); available.After(now) {
+			return min(max(available.Sub(now), minSeriesCacheTTL), maxSeriesCacheTTL)
+		}
+	}
+	return seriesCacheTTL
+}
+
+// result wraps chart data computed from the set, along with the fetch
+// reports of the given series
+func (s *seriesSet) result(data []templates.LineChartData, ids ...string) *chartResult {
+	reports := make([]fred.FetchReport, 0, len(ids))
+	for _, id := range ids {
+		if series, ok := s.Series[id]; ok {
+			reports = append(reports, series.Report)
+		}
+	}
+
+	return &chartResult{
+		Data:       data,
+		Reports:    reports,
+		UpdatedAt:  s.UpdatedAt,
+		RefreshErr: s.RefreshErr,
+	}
+}
+
+// pointsFrom returns the points dated on or after start, or all points if
+// start is nil
+func pointsFrom(points []fred.DataPoint, start *time.Time) []fred.DataPoint {
+	if start == nil {
+		return points
+	}
+
+	filtered := make([]fred.DataPoint, 0, len(points))
+	for _, p := range points {
+		if !p.Date.Before(*start) {
+			filtered = append(filtered, p)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shanehull/shanehull.com/internal/fred"
+	"github.com/shanehull/shanehull.com/internal/fred/fredtest"
+)
+
+func TestReleaseTTL(t *testing.T) {
+	day := func(d int) time.Time {
+		return time.Date(2024, 3, d, 0, 0, 0, 0, time.UTC)
+	}
+	dates := []time.Time{day(1), day(14), day(28)}
+
+	tests := []struct {
+		name  string
+		dates []time.Time
+		now   time.Time
+		want  time.Duration
+	}{
+		{"before release day", dates, day(10), 4*24*time.Hour + releaseTime},
+		{"release day before release time", dates, day(14).Add(12 * time.Hour), releaseTime - 12*time.Hour},
+		{"release day after release time", dates, day(14).Add(18 * time.Hour), 14*24*time.Hour - time.Hour},
+		{"just before release time", dates, day(14).Add(releaseTime - time.Minute), minSeriesCacheTTL},
+		{"after the last release", dates, day(29), seriesCacheTTL},
+		{"no releases", nil, day(10), seriesCacheTTL},
+		{"release far off", []time.Time{day(1).AddDate(1, 0, 0)}, day(1), maxSeriesCacheTTL},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := releaseTTL(tt.dates, tt.now); got != tt.want {
+				t.Errorf("releaseTTL() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReleaseDatesCached(t *testing.T) {
+	srv := fredtest.NewServer()
+	defer srv.Close()
+
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	srv.AddSeries("GDP", fred.FrequencyQuarterly, fredtest.Points(fred.FrequencyQuarterly, start, 1, 2, 3))
+	srv.AddSeries("USREC", fred.FrequencyMonthly, fredtest.Points(fred.FrequencyMonthly, start, 0, 1, 0))
+	srv.SetRelease("GDP", fred.Release{ID: 53, Name: "Gross Domestic Product"}, time.Now().AddDate(0, 0, 30))
+
+	h := NewChartHandlers(srv.Client())
+	defer h.Close()
+
+	// The same series at different frequencies is cached twice, but its
+	// release calendar is looked up once. A series with no release is
+	// looked up once too.
+	for _, freq := range []fred.Frequency{fred.FrequencyQuarterly, fred.FrequencyAnnual} {
+		for _, id := range []string{"GDP", "USREC"} {
+			opts := &fred.FetchOptions{Frequency: freq, AggregationMethod: fred.AggregationAverage}
+			if _, err := h.getOrFetchSeries(context.Background(), h.seriesCache, nil, fred.SeriesRequest{ID: id, Options: opts}); err != nil {
+				t.Fatalf("getOrFetchSeries(%s, %s) error = %v", id, freq, err)
+			}
+		}
+	}
+
+	counts := make(map[string]int)
+	for _, r := range srv.Requests() {
+		counts[r.URL.Path]++
+	}
+	if counts["/series/observations"] != 4 {
+		t.Errorf("fetched observations %d times, want 4", counts["/series/observations"])
+	}
+	if counts["/series/release"] != 2 {
+		t.Errorf("fetched series releases %d times, want 2", counts["/series/release"])
+	}
+	if counts["/release/dates"] != 1 {
+		t.Errorf("fetched release dates %d times, want 1", counts["/release/dates"])
+	}
+
+	// The series is kept until its next release
+	for _, e := range h.seriesCache.Entries() {
+		if ttl := e.ExpiresAt.Sub(e.UpdatedAt); strings.HasPrefix(e.Key, "series:GDP:") && ttl < 29*24*time.Hour {
+			t.Errorf("%s expires after %v, want about 30 days", e.Key, ttl)
+		}
+	}
+}
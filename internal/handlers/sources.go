@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/shanehull/shanehull.com/internal/fred"
+	"github.com/shanehull/shanehull.com/internal/templates"
+)
+
+const (
+	sourcesCacheTTL = 24 * time.Hour
+
+	// sourcesCacheEntries bounds the sources cache, which custom formulas
+	// can fill with any series
+	sourcesCacheEntries = 256
+)
+
+// seriesInfoSize estimates the memory used by cached series metadata
+func seriesInfoSize(info *fred.SeriesInfo) int64 {
+	return int64(len(info.Title) + len(info.Notes) + len(info.Units) + len(info.Frequency) + len(info.SeasonalAdjustment) + 128)
+}
+
+// getOrFetchDataSource returns the data source panel entry for a series,
+// falling back to just the series ID if its metadata can't be fetched
+func (h *ChartHandlers) getOrFetchDataSource(ctx context.Context, src Input) templates.DataSource {
+	ds := templates.DataSource{
+		Role:     src.Role,
+		SeriesID: src.SeriesID,
+	}
+
+	cacheKey := "sources:" + src.SeriesID
+
+	info, err := h.sourcesCache.GetOrLoad(ctx, cacheKey, func(ctx context.Context) (*fred.SeriesInfo, time.Duration, error) {
+		info, err := h.fred.FetchSeriesInfo(ctx, src.SeriesID)
+		return info, sourcesCacheTTL, err
+	})
+	if err != nil {
+		log.Print("failed to get series info:", err)
+		return ds
+	}
+
+	ds.Title = info.Title
+	ds.Units = info.Units
+	ds.Frequency = info.Frequency
+	ds.SeasonalAdjustment = info.SeasonalAdjustment
+	ds.ObservationStart = info.ObservationStart
+	ds.ObservationEnd = info.ObservationEnd
+	ds.LastUpdated = info.LastUpdated
+	if updated, err := info.LastUpdatedTime(); err == nil {
+		ds.LastUpdated = updated.Format("2006-01-02")
+	}
+	ds.Notes = info.Notes
+
+	return ds
+}
+
+// SourcesHandler renders the data source panel for the chart tool named by
+// the {tool} path value, or for the series in a custom formula
+func (h *ChartHandlers) SourcesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	tool := r.PathValue("tool")
+	ind, ok := h.indicator(tool)
+	if tool == customSlug {
+		custom, err := h.customIndicator(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		ind, ok = custom, true
+	}
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	sources := ind.Inputs()
+	data := make([]templates.DataSource, len(sources))
+	for i, src := range sources {
+		data[i] = h.getOrFetchDataSource(r.Context(), src)
+	}
+
+	component := templates.ChartSources(data)
+
+	buf := new(bytes.Buffer)
+	defer buf.Reset()
+
+	if err := component.Render(r.Context(), buf); err != nil {
+		log.Print("failed to render component:", err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		log.Print("failed to write response:", err)
+	}
+}
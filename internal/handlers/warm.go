@@ -0,0 +1,27 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Warm computes every registered indicator and loads the data source
+// metadata, so that visitors are served from the cache. Every range is
+// computed from the same cached series, so each indicator is computed once
+// over its full history. Series that are missing are fetched; stale ones are
+// refreshed in the background.
+func (h *ChartHandlers) Warm(ctx context.Context) error {
+	var errs []error
+	for _, ind := range h.Indicators() {
+		if _, err := h.getOrFetchIndicator(ctx, ind, "max", false, nil); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", ind.Slug(), err))
+		}
+
+		for _, src := range ind.Inputs() {
+			h.getOrFetchDataSource(ctx, src)
+		}
+	}
+
+	return errors.Join(errs...)
+}
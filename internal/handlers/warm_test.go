@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestWarm(t *testing.T) {
+	srv := newTestServer(t)
+	h := newTestHandlers(t, srv)
+
+	if err := h.Warm(context.Background()); err != nil {
+		t.Fatalf("Warm() error = %v", err)
+	}
+
+	// Each input is fetched once, however many ranges the charts offer
+	fetched := make(map[string]int)
+	for _, r := range srv.Requests() {
+		if r.URL.Path == "/series/observations" {
+			fetched[r.URL.Query().Get("series_id")]++
+		}
+	}
+	for _, ind := range h.Indicators() {
+		for _, in := range ind.Inputs() {
+			if n := fetched[in.SeriesID]; n != 1 {
+				t.Errorf("%s fetched %d times, want 1", in.SeriesID, n)
+			}
+		}
+	}
+}
+
+func TestWarmError(t *testing.T) {
+	srv := newTestServer(t)
+	h := newTestHandlers(t, srv)
+
+	// Fail every request, including the retries
+	failures := make([]int, 100)
+	for i := range failures {
+		failures[i] = http.StatusInternalServerError
+	}
+	srv.FailNext(failures...)
+
+	err := h.Warm(context.Background())
+	if err == nil {
+		t.Fatal("Warm() error = nil, want the failed indicators")
+	}
+	for _, ind := range h.Indicators() {
+		if n := strings.Count(err.Error(), ind.Slug()+":"); n != 1 {
+			t.Errorf("Warm() error names %s %d times, want 1: %v", ind.Slug(), n, err)
+		}
+	}
+}
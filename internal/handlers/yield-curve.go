@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/shanehull/shanehull.com/internal/fred"
+	"github.com/shanehull/shanehull.com/internal/templates"
+)
+
+const (
+	spread10y2yID = "T10Y2Y"
+	spread10y3mID = "T10Y3M"
+)
+
+// inversionColor shades periods when the yield curve is inverted
+const inversionColor = "rgba(239, 68, 68, 0.15)"
+
+// yieldCurveSpread is a Treasury spread the yield curve tool can show,
+// selected by its spread query param
+type yieldCurveSpread struct {
+	Input
+	Param  string
+	Title  string
+	Column string
+}
+
+// yieldCurveSpreads are the spreads the tool can show. The first is the
+// default.
+var yieldCurveSpreads = []yieldCurveSpread{
+	{
+		Input:  Input{Role: "10-Year minus 2-Year", SeriesID: spread10y2yID},
+		Param:  "10y2y",
+		Title:  "10-Year minus 2-Year Treasury Spread",
+		Column: "spread_10y2y",
+	},
+	{
+		Input:  Input{Role: "10-Year minus 3-Month", SeriesID: spread10y3mID},
+		Param:  "10y3m",
+		Title:  "10-Year minus 3-Month Treasury Spread",
+		Column: "spread_10y3m",
+	},
+}
+
+// yieldCurveFrequencies are the frequencies the daily spreads can be
+// averaged to. Monthly is the default.
+var yieldCurveFrequencies = []fred.Frequency{
+	fred.FrequencyDaily,
+	fred.FrequencyWeekly,
+	fred.FrequencyMonthly,
+}
+
+// yieldCurve is the spread between long and short Treasury yields, with
+// inversions shaded. The spread and frequency are selected by the spread
+// and frequency query params.
+type yieldCurve struct {
+	*indicator
+}
+
+var yieldCurveIndicator = newYieldCurve()
+
+// newYieldCurve returns the default variant, the monthly 10-Year minus
+// 2-Year spread, which lists both spreads as inputs so that both are
+// warmed and shown as data sources
+func newYieldCurve() *yieldCurve {
+	def := yieldCurveVariant(yieldCurveSpreads[0], fred.FrequencyMonthly)
+	def.inputs = nil
+	for _, s := range yieldCurveSpreads {
+		in := s.Input
+		in.Options = yieldCurveOptions(fred.FrequencyMonthly)
+		def.inputs = append(def.inputs, in)
+	}
+	return &yieldCurve{indicator: def}
+}
+
+func (y *yieldCurve) Variant(r *http.Request) (Indicator, error) {
+	query := r.URL.Query()
+
+	spread := yieldCurveSpreads[0]
+	if param := query.Get("spread"); param != "" {
+		i := slices.IndexFunc(yieldCurveSpreads, func(s yieldCurveSpread) bool {
+			return s.Param == param
+		})
+		if i < 0 {
+			return nil, fmt.Errorf("invalid spread %q", param)
+		}
+		spread = yieldCurveSpreads[i]
+	}
+
+	freq := fred.FrequencyMonthly
+	if f := query.Get("frequency"); f != "" {
+		freq = fred.Frequency(f)
+		if !slices.Contains(yieldCurveFrequencies, freq) {
+			return nil, fmt.Errorf("invalid frequency %q", f)
+		}
+	}
+
+	return yieldCurveVariant(spread, freq), nil
+}
+
+func (y *yieldCurve) VariantParams() []string {
+	return []string{"spread", "frequency"}
+}
+
+// yieldCurveVariant returns the indicator for a spread at a frequency
+func yieldCurveVariant(spread yieldCurveSpread, freq fred.Frequency) *indicator {
+	in := spread.Input
+	in.Options = yieldCurveOptions(freq)
+
+	return &indicator{
+		slug:   "yield-curve",
+		inputs: []Input{in},
+		labels: Labels{
+			Title:  spread.Title,
+			YAxis:  "Spread (%)",
+			Column: spread.Column,
+		},
+		overlay: OverlayAverage,
+		compute: func(series map[string][]fred.DataPoint, _ *time.Time) ([]fred.DataPoint, error) {
+			return series[in.SeriesID], nil
+		},
+		shade: inversions,
+	}
+}
+
+// yieldCurveOptions fetches a spread averaged to freq. The spreads are
+// daily, so at daily frequency there is nothing to average, and FRED
+// rejects an aggregation method.
+func yieldCurveOptions(freq fred.Frequency) *fred.FetchOptions {
+	opts := &fred.FetchOptions{
+		Frequency:         freq,
+		AggregationMethod: fred.AggregationAverage,
+		Units:             fred.UnitsLevels,
+		KeepMissing:       true,
+	}
+	if freq == fred.FrequencyDaily {
+		opts.AggregationMethod = ""
+	}
+	return opts
+}
+
+// inversions returns a band for each run of negative values. Missing values
+// don't end a run.
+func inversions(data []templates.LineChartData) []templates.ChartBand {
+	var bands []templates.ChartBand
+	inverted := false
+	for _, d := range data {
+		if d.Missing {
+			continue
+		}
+		if d.Value >= 0 {
+			inverted = false
+			continue
+		}
+		if !inverted {
+			bands = append(bands, templates.ChartBand{
+				Start: d.Date,
+				Label: "Inverted",
+				Color: inversionColor,
+			})
+			inverted = true
+		}
+		bands[len(bands)-1].End = d.Date
+	}
+	return bands
+}
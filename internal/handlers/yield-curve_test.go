@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/shanehull/shanehull.com/internal/templates"
+)
+
+func TestYieldCurve(t *testing.T) {
+	h := newTestHandlers(t, newTestServer(t))
+
+	for _, spread := range yieldCurveSpreads {
+		for _, freq := range yieldCurveFrequencies {
+			query := fmt.Sprintf("?spread=%s&frequency=%s&average=on&recessions=on", spread.Param, freq)
+			t.Run(spread.Param+"/"+string(freq), func(t *testing.T) {
+				rec := serve(h, http.MethodGet, "/yield-curve/chart"+query)
+				if strings.Contains(rec.Body.String(), "chart-error") {
+					t.Fatalf("chart error: %s", rec.Body)
+				}
+				config := parseChart(t, rec.Body.String())
+				if len(config.Labels) == 0 {
+					t.Fatal("chart has no data")
+				}
+				if config.Datasets[0].Label != spread.Title {
+					t.Errorf("label = %q, want %q", config.Datasets[0].Label, spread.Title)
+				}
+				// The spread goes negative every year, and 2008 was a
+				// recession
+				var labels []string
+				for _, ds := range config.Datasets {
+					labels = append(labels, ds.Label)
+				}
+				if want := []string{spread.Title, "Average", "Recession", "Inverted"}; strings.Join(labels, ",") != strings.Join(want, ",") {
+					t.Errorf("datasets = %q, want %q", labels, want)
+				}
+
+				rec = serve(h, http.MethodGet, "/yield-curve/data"+query)
+				var data []templates.LineChartData
+				if err := json.NewDecoder(rec.Body).Decode(&data); err != nil || rec.Code != http.StatusOK {
+					t.Fatalf("GET data = %d, %v", rec.Code, err)
+				}
+				if len(data) != len(config.Labels) {
+					t.Errorf("data has %d points, chart has %d", len(data), len(config.Labels))
+				}
+
+				rec = serve(h, http.MethodGet, "/yield-curve/data.csv"+query)
+				rows, err := csv.NewReader(rec.Body).ReadAll()
+				if err != nil || rec.Code != http.StatusOK {
+					t.Fatalf("GET data.csv = %d, %v", rec.Code, err)
+				}
+				if got, want := strings.Join(rows[0], ","), "date,"+spread.Column+",average"; got != want {
+					t.Errorf("CSV header = %s, want %s", got, want)
+				}
+				if len(rows)-1 != len(data) {
+					t.Errorf("CSV has %d rows, want %d", len(rows)-1, len(data))
+				}
+			})
+		}
+	}
+}
+
+func TestYieldCurveInvalidParams(t *testing.T) {
+	h := newTestHandlers(t, newTestServer(t))
+
+	for _, query := range []string{"?spread=5y2y", "?frequency=q"} {
+		rec := serve(h, http.MethodGet, "/yield-curve/chart"+query)
+		if !strings.Contains(rec.Body.String(), "chart-error") {
+			t.Errorf("GET chart%s = %s, want an error", query, rec.Body)
+		}
+		if rec := serve(h, http.MethodGet, "/yield-curve/data"+query); rec.Code != http.StatusBadRequest {
+			t.Errorf("GET data%s = %d, want 400", query, rec.Code)
+		}
+	}
+}
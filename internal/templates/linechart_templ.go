@@ -10,7 +10,7 @@ import templruntime "github.com/a-h/templ/runtime"
 
 import (
 	"encoding/json"
-	"strings"
+	"math"
 )
 
 type LineChartData struct {
@@ -19,9 +19,47 @@ type LineChartData struct {
 	Quartile1 float64 `json:"quartile1,omitempty"`
 	Quartile3 float64 `json:"quartile3,omitempty"`
 	Average   float64 `json:"average,omitempty"`
+	Missing   bool    `json:"missing,omitempty"`
 }
 
-func LineChart(canvasId string, data []LineChartData, showQuartiles bool, options map[string]string) templ.Component {
+// ChartBand is a period shaded on a chart, such as a recession. Start and
+// End are inclusive dates in the same format as the chart labels.
+type ChartBand struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+	Label string `json:"label"`
+	Color string `json:"color"`
+}
+
+// MarshalJSON encodes missing values as null, since JSON has no NaN
+func (d LineChartData) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Date      string   `json:"date"`
+		Value     *float64 `json:"value"`
+		Quartile1 float64  `json:"quartile1,omitempty"`
+		Quartile3 float64  `json:"quartile3,omitempty"`
+		Average   float64  `json:"average,omitempty"`
+		Missing   bool     `json:"missing,omitempty"`
+	}{
+		Date:      d.Date,
+		Value:     nullable(d.Value),
+		Quartile1: d.Quartile1,
+		Quartile3: d.Quartile3,
+		Average:   d.Average,
+		Missing:   d.Missing,
+	})
+}
+
+// nullable returns nil for NaN so it encodes as a JSON null, which Chart.js
+// draws as a gap
+func nullable(v float64) *float64 {
+	if math.IsNaN(v) {
+		return nil
+	}
+	return &v
+}
+
+func LineChart(canvasId string, data []LineChartData, showQuartiles bool, options map[string]string, bands []ChartBand) templ.Component {
 	return templruntime.GeneratedTemplate(func(templ_7745c5c3_Input templruntime.GeneratedComponentInput) (templ_7745c5c3_Err error) {
 		templ_7745c5c3_W, ctx := templ_7745c5c3_Input.Writer, templ_7745c5c3_Input.Context
 		if templ_7745c5c3_CtxErr := ctx.Err(); templ_7745c5c3_CtxErr != nil {
@@ -42,15 +80,34 @@ func LineChart(canvasId string, data []LineChartData, showQuartiles bool, option
 			templ_7745c5c3_Var1 = templ.NopComponent
 		}
 		ctx = templ.ClearChildren(ctx)
-		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 1, "<div")
+		if options["dataAsOf"] != "" {
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 1, "<p class=\"chart-notice\">Showing data as of ")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			var templ_7745c5c3_Var2 string
+			templ_7745c5c3_Var2, templ_7745c5c3_Err = templ.JoinStringErrs(options["dataAsOf"])
+			if templ_7745c5c3_Err != nil {
+				return templ.Error{Err: templ_7745c5c3_Err, FileName: `internal/templates/linechart.templ`, Line: 56, Col: 66}
+			}
+			_, templ_7745c5c3_Err = templ_7745c5c3_Buffer.WriteString(templ.EscapeString(templ_7745c5c3_Var2))
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+			templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 2, ". The latest data could not be loaded.</p>")
+			if templ_7745c5c3_Err != nil {
+				return templ_7745c5c3_Err
+			}
+		}
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 3, "<div")
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
-		templ_7745c5c3_Err = templ.RenderAttributes(ctx, templ_7745c5c3_Buffer, buildChartDataAttributes(canvasId, data, showQuartiles, options))
+		templ_7745c5c3_Err = templ.RenderAttributes(ctx, templ_7745c5c3_Buffer, buildChartDataAttributes(canvasId, data, showQuartiles, options, bands))
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
-		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 2, "></div>")
+		templ_7745c5c3_Err = templruntime.WriteString(templ_7745c5c3_Buffer, 4, "></div>")
 		if templ_7745c5c3_Err != nil {
 			return templ_7745c5c3_Err
 		}
@@ -58,49 +115,116 @@ func LineChart(canvasId string, data []LineChartData, showQuartiles bool, option
 	})
 }
 
-func buildChartDataAttributes(canvasId string, data []LineChartData, showQuartiles bool, options map[string]string) templ.Attributes {
+// chartDataset is a Chart.js dataset
+type chartDataset struct {
+	Label           string     `json:"label"`
+	Data            []*float64 `json:"data"`
+	BorderColor     string     `json:"borderColor"`
+	BackgroundColor string     `json:"backgroundColor,omitempty"`
+	BorderDash      []int      `json:"borderDash,omitempty"`
+	BorderWidth     int        `json:"borderWidth"`
+	Fill            *bool      `json:"fill,omitempty"`
+	Tension         float64    `json:"tension,omitempty"`
+	PointRadius     int        `json:"pointRadius"`
+}
+
+// chartConfig is the data-chart attribute read by the chart script
+type chartConfig struct {
+	Labels     []string       `json:"labels"`
+	Datasets   []chartDataset `json:"datasets"`
+	Bands      []ChartBand    `json:"bands"`
+	YAxisLabel string         `json:"yAxisLabel"`
+	CanvasID   string         `json:"canvasId"`
+}
+
+func buildChartDataAttributes(canvasId string, data []LineChartData, showQuartiles bool, options map[string]string, bands []ChartBand) templ.Attributes {
 	labels := make([]string, len(data))
-	values := make([]float64, len(data))
-	q1 := make([]float64, len(data))
-	q3 := make([]float64, len(data))
-	avg := make([]float64, len(data))
+	values := make([]*float64, len(data))
+	q1 := make([]*float64, len(data))
+	q3 := make([]*float64, len(data))
+	avg := make([]*float64, len(data))
 
 	for i, d := range data {
 		labels[i] = d.Date
-		values[i] = d.Value
-		q1[i] = d.Quartile1
-		q3[i] = d.Quartile3
-		avg[i] = d.Average
+		values[i] = nullable(d.Value)
+		q1[i] = nullable(d.Quartile1)
+		q3[i] = nullable(d.Quartile3)
+		avg[i] = nullable(d.Average)
 	}
 
-	labelsJSON, _ := json.Marshal(labels)
-	valuesJSON, _ := json.Marshal(values)
-	q1JSON, _ := json.Marshal(q1)
-	q3JSON, _ := json.Marshal(q3)
-	avgJSON, _ := json.Marshal(avg)
-
-	// Escape options for use in JSON
-	mainLabel := strings.ReplaceAll(options["mainLabel"], `"`, `\"`)
-	yAxisLabel := strings.ReplaceAll(options["yAxisLabel"], `"`, `\"`)
-
-	datasets := `[{"label":"` + mainLabel + `","data":` + string(valuesJSON) + `,"borderColor":"#3b82f6","backgroundColor":"rgba(59, 130, 246, 0.1)","borderWidth":2,"fill":true,"tension":0.1,"pointRadius":1}`
+	fill, noFill := true, false
+	datasets := []chartDataset{{
+		Label:           options["mainLabel"],
+		Data:            values,
+		BorderColor:     "#3b82f6",
+		BackgroundColor: "rgba(59, 130, 246, 0.1)",
+		BorderWidth:     2,
+		Fill:            &fill,
+		Tension:         0.1,
+		PointRadius:     1,
+	}}
 
 	// Add average line if requested
 	if options["showAverage"] == "true" {
-		datasets += `,{"label":"Average","data":` + string(avgJSON) + `,"borderColor":"#8b5cf6","borderDash":[3,3],"borderWidth":2,"fill":false,"pointRadius":0}`
+		datasets = append(datasets, chartDataset{
+			Label:       "Average",
+			Data:        avg,
+			BorderColor: "#8b5cf6",
+			BorderDash:  []int{3, 3},
+			BorderWidth: 2,
+			Fill:        &noFill,
+		})
 	}
 
 	// Add quartiles if requested
 	if options["showQuartiles"] == "true" {
-		datasets += `,{"label":"Q1 (25th percentile)","data":` + string(q1JSON) + `,"borderColor":"rgba(200, 100, 100, 0.6)","borderDash":[5,5],"borderWidth":1,"fill":false,"pointRadius":0},{"label":"Q3 (75th percentile)","data":` + string(q3JSON) + `,"borderColor":"rgba(100, 200, 100, 0.6)","borderDash":[5,5],"borderWidth":1,"fill":false,"pointRadius":0}`
+		datasets = append(datasets, chartDataset{
+			Label:       "Q1 (25th percentile)",
+			Data:        q1,
+			BorderColor: "rgba(200, 100, 100, 0.6)",
+			BorderDash:  []int{5, 5},
+			BorderWidth: 1,
+			Fill:        &noFill,
+		}, chartDataset{
+			Label:       "Q3 (75th percentile)",
+			Data:        q3,
+			BorderColor: "rgba(100, 200, 100, 0.6)",
+			BorderDash:  []int{5, 5},
+			BorderWidth: 1,
+			Fill:        &noFill,
+		})
 	}
 
-	datasets += `]`
+	// Add a legend entry for each kind of band, which also toggles them
+	legends := make(map[string]bool)
+	for _, band := range bands {
+		if legends[band.Label] {
+			continue
+		}
+		legends[band.Label] = true
+		datasets = append(datasets, chartDataset{
+			Label:           band.Label,
+			Data:            []*float64{},
+			BorderColor:     band.Color,
+			BackgroundColor: band.Color,
+		})
+	}
 
-	configJSON := `{"labels":` + string(labelsJSON) + `,"datasets":` + datasets + `,"yAxisLabel":"` + yAxisLabel + `","canvasId":"` + canvasId + `"}`
+	if bands == nil {
+		bands = []ChartBand{}
+	}
+
+	// Every value is a string or a nullable number, so this can't fail
+	configJSON, _ := json.Marshal(chartConfig{
+		Labels:     labels,
+		Datasets:   datasets,
+		Bands:      bands,
+		YAxisLabel: options["yAxisLabel"],
+		CanvasID:   canvasId,
+	})
 
 	return templ.Attributes{
-		"data-chart": configJSON,
+		"data-chart": string(configJSON),
 	}
 }
 
@@ -0,0 +1,64 @@
+package templates
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func TestChartDataAttributes(t *testing.T) {
+	label := "A \"quoted\" \\ back\tslash\n</div><script>"
+	data := []LineChartData{
+		{Date: "2024-01-01", Value: 1, Average: 1.5},
+		{Date: "2024-02-01", Value: math.NaN(), Missing: true, Average: 1.5},
+	}
+	bands := []ChartBand{
+		{Start: "2024-01-01", End: "2024-02-01", Label: "Recession", Color: "grey"},
+		{Start: "2024-02-01", End: "2024-02-01", Label: "Recession", Color: "grey"},
+	}
+	options := map[string]string{
+		"mainLabel":   label,
+		"yAxisLabel":  "Rate (%)",
+		"showAverage": "true",
+	}
+
+	attrs := buildChartDataAttributes("chart", data, false, options, bands)
+
+	var config struct {
+		Labels   []string `json:"labels"`
+		Datasets []struct {
+			Label string     `json:"label"`
+			Data  []*float64 `json:"data"`
+		} `json:"datasets"`
+		Bands      []ChartBand `json:"bands"`
+		YAxisLabel string      `json:"yAxisLabel"`
+		CanvasID   string      `json:"canvasId"`
+	}
+	if err := json.Unmarshal([]byte(attrs["data-chart"].(string)), &config); err != nil {
+		t.Fatalf("data-chart is not valid JSON: %v", err)
+	}
+
+	if config.Datasets[0].Label != label {
+		t.Errorf("label = %q, want %q", config.Datasets[0].Label, label)
+	}
+	if config.YAxisLabel != "Rate (%)" || config.CanvasID != "chart" {
+		t.Errorf("yAxisLabel, canvasId = %q, %q", config.YAxisLabel, config.CanvasID)
+	}
+
+	// The main line, the average and one legend entry for the bands
+	var labels []string
+	for _, ds := range config.Datasets {
+		labels = append(labels, ds.Label)
+	}
+	if len(labels) != 3 || labels[1] != "Average" || labels[2] != "Recession" {
+		t.Errorf("datasets = %q, want the main line, Average and Recession", labels)
+	}
+
+	// Missing values are gaps
+	if values := config.Datasets[0].Data; values[0] == nil || *values[0] != 1 || values[1] != nil {
+		t.Errorf("values = %v, want 1 and a gap", values)
+	}
+	if len(config.Bands) != 2 {
+		t.Errorf("bands = %v, want 2", config.Bands)
+	}
+}